@@ -0,0 +1,66 @@
+package flatjson
+
+import (
+	"context"
+	"io"
+)
+
+// Result summarizes the shape of a document along with its pairs.
+type Result struct {
+	// Flattened pairs of the document.
+	Pairs []*Pair
+
+	// Type of the top-level value, or the first of several. A scalar
+	// is flattened to a single pair with an empty key, while both maps
+	// and arrays produce keys by path.
+	RootKind Kind
+
+	// Deepest nesting of maps and arrays, where a top-level scalar has a
+	// depth of zero and {"a": 1} a depth of one.
+	MaxDepth int
+
+	// Number of pairs by the kind of their value.
+	Kinds map[Kind]int
+
+	// Denotes at least one empty map or array was collapsed to null, or the
+	// value set by WithEmptyValue.
+	CollapsedEmpty bool
+}
+
+// Analyze flattens a JSON value like Parse and summarizes its shape.
+func Analyze(r io.Reader, opts ...Option) (*Result, error) {
+	o := newOptions(opts)
+
+	p := newReaderParser(context.Background(), r, &o)
+
+	res := &Result{
+		Kinds: make(map[Kind]int),
+	}
+
+	for {
+		pair, err := p.next()
+
+		// Continue with the next top-level value, if any.
+		if err == io.EOF && p.started {
+			p.reset()
+			continue
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		res.Pairs = append(res.Pairs, pair)
+		res.Kinds[pair.Kind()]++
+	}
+
+	res.MaxDepth = p.depth
+	res.CollapsedEmpty = p.collapsed
+	res.RootKind = p.root
+
+	return res, nil
+}
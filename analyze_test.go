@@ -0,0 +1,89 @@
+package flatjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	input := `{"name": "Bob", "age": 30, "tags": [], "address": {"geo": {"lat": 1.5}}, "ok": true, "x": null}`
+
+	res, err := Analyze(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Pairs) != 6 {
+		t.Errorf("expected 6 pairs, got %d", len(res.Pairs))
+	}
+
+	if res.MaxDepth != 3 {
+		t.Errorf("expected depth 3, got %d", res.MaxDepth)
+	}
+
+	kinds := map[Kind]int{
+		StringKind: 1,
+		NumberKind: 2,
+		NullKind:   2,
+		BoolKind:   1,
+	}
+
+	if !reflect.DeepEqual(res.Kinds, kinds) {
+		t.Errorf("expected %v, got %v", kinds, res.Kinds)
+	}
+
+	if !res.CollapsedEmpty {
+		t.Error("expected an empty collection to be collapsed")
+	}
+
+	res, err = Analyze(strings.NewReader(input), WithEmptyCollections(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.CollapsedEmpty {
+		t.Error("expected empty collections to be kept")
+	}
+
+	if res.Kinds[ArrayKind] != 1 {
+		t.Errorf("expected 1 array, got %d", res.Kinds[ArrayKind])
+	}
+
+	res, err = Analyze(strings.NewReader(`"scalar"`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.MaxDepth != 0 {
+		t.Errorf("expected depth 0, got %d", res.MaxDepth)
+	}
+}
+
+func TestAnalyzeRootKind(t *testing.T) {
+	tests := map[string]Kind{
+		`{"a": [1]}`:      ObjectKind,
+		`[{"a": 1}]`:      ArrayKind,
+		`{}`:              ObjectKind,
+		`[]`:              ArrayKind,
+		`"a"`:             StringKind,
+		`1.5`:             NumberKind,
+		`null`:            NullKind,
+		`[1] {"a": true}`: ArrayKind,
+	}
+
+	for input, expected := range tests {
+		res, err := Analyze(strings.NewReader(input))
+
+		if err != nil {
+			t.Fatalf("%s: %s", input, err)
+		}
+
+		if res.RootKind != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, res.RootKind)
+		}
+	}
+}
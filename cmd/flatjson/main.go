@@ -1,16 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/bruth/flatjson"
 )
 
-var usage = `usage: flatjson [options] [path]
+var usage = `usage: flatjson [options] [path ...]
 
 flatjson takes a JSON string and re-encodes into a flat map or array of
 key-value pairs.
@@ -25,53 +28,218 @@ Examples:
 
     flatjson -array file.json
 
+  Read newline-delimited JSON, output one flat map per line:
+
+    flatjson -lines file.jsonl
+
+  Read several files, output one result for each in turn:
+
+    flatjson a.json b.json
+
+  Read several files, output an array with the flat array of each:
+
+    flatjson -array a.json b.json
+
+  Write the output to a file:
+
+    flatjson -o flat.json file.json
+
+  Reconstruct nested JSON from a flat map, keeping empty maps and arrays
+  rather than turning them into null:
+
+    flatjson -empty-collections file.json | flatjson -unflatten
+
+  Read gzip-compressed JSON:
+
+    cat file.json.gz | flatjson -gzip
+
 Options:
 
 `
 
-func init() {
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, usage)
-		flag.PrintDefaults()
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func main() {
-	var array bool
+// run runs the command with the arguments, reading standard input from
+// stdin and writing to stdout unless an output file is given.
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("flatjson", flag.ExitOnError)
 
-	flag.BoolVar(&array, "array", false, "Output as an array of pairs.")
-	flag.Parse()
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
 
-	args := flag.Args()
+	var (
+		array     bool
+		lines     bool
+		seq       bool
+		csv       bool
+		sortKeys  bool
+		indent    int
+		filter    string
+		sep       string
+		notation  string
+		output    string
+		gz        bool
+		unflatten bool
+		empty     bool
+	)
+
+	fs.BoolVar(&array, "array", false, "Output as an array of pairs, or an array of the arrays of several files.")
+	fs.BoolVar(&csv, "csv", false, "Output as CSV of keys and JSON-encoded values.")
+	fs.BoolVar(&lines, "lines", false, "Read newline-delimited JSON and output one result per line.")
+	fs.BoolVar(&seq, "seq", false, "Read concatenated JSON values and output an array with the flat map of each.")
+	fs.BoolVar(&sortKeys, "sort", false, "Sort pairs by key.")
+	fs.IntVar(&indent, "indent", 0, "Number of spaces to indent the output by.")
+	fs.StringVar(&filter, "filter", "", "Only output keys matching the pattern, e.g. address.* or **.zipcode.")
+	fs.StringVar(&sep, "sep", ".", "Separator between key path segments.")
+	fs.StringVar(&notation, "array-notation", "bracket", "Array index notation: bracket, dotted or plain.")
+	fs.StringVar(&output, "o", "", "Write the output to a file instead of stdout.")
+	fs.BoolVar(&unflatten, "unflatten", false, "Read a flat map and output the nested JSON it was flattened from.")
+	fs.BoolVar(&empty, "empty-collections", false, "Keep empty maps and arrays as {} and [] instead of null.")
+	fs.BoolVar(&gz, "gzip", false, "Decompress gzip input. Files ending in .gz are always decompressed.")
+	fs.Parse(args)
+
+	if indent < 0 {
+		return fmt.Errorf("indent must not be negative")
+	}
 
-	var r io.Reader
+	an, err := flatjson.ParseArrayNotation(notation)
 
-	// Use stdin if no path is supplied.
-	if len(args) == 0 {
-		r = os.Stdin
-	} else {
-		f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := stdout
+
+	// The file is created, or truncated, before any input is read.
+	if output != "" {
+		file, err := os.Create(output)
 
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		defer f.Close()
+		out = file
+	}
 
-		r = f
+	enc := flatjson.NewEncoder(out,
+		flatjson.WithSeparator(sep),
+		flatjson.WithArrayNotation(an),
+		flatjson.WithSortKeys(sortKeys),
+		flatjson.WithIndent(strings.Repeat(" ", indent)),
+		flatjson.WithKeyFilter(filter),
+		flatjson.WithEmptyCollections(empty),
+	)
+
+	var convert func(io.Reader) error
+
+	switch {
+	case unflatten:
+		convert = enc.Decode
+	case csv:
+		convert = enc.ConvertCSV
+	case seq:
+		convert = enc.ConvertMapSequence
+	case array && lines:
+		convert = enc.ConvertArrayLines
+	case array:
+		convert = enc.ConvertArray
+	case lines:
+		convert = enc.ConvertMapLines
+	default:
+		convert = enc.ConvertMap
 	}
 
-	enc := flatjson.NewEncoder(os.Stdout)
+	paths := fs.Args()
+
+	// The arrays of several files are collected into one array.
+	var results [][]byte
+
+	if array && !lines && !csv && !seq && !unflatten && len(paths) > 1 {
+		buf := bytes.NewBuffer(nil)
+		enc.Reset(buf)
+
+		convert = func(r io.Reader) error {
+			buf.Reset()
 
-	var err error
+			if err := enc.ConvertArray(r); err != nil {
+				return err
+			}
 
-	if array {
-		err = enc.ConvertArray(r)
-	} else {
-		err = enc.ConvertMap(r)
+			results = append(results, append([]byte(nil), bytes.TrimSpace(buf.Bytes())...))
+
+			return nil
+		}
 	}
 
-	if err != nil {
-		log.Fatal(err)
+	// Use stdin if no path is supplied.
+	if len(paths) == 0 {
+		if gz {
+			err = flatjson.ConvertGzip(stdin, convert)
+		} else {
+			err = convert(stdin)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Each file is flattened separately and written in turn. Every result
+	// ends with a newline.
+	for _, path := range paths {
+		if err := convertFile(path, gz, convert); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	if results != nil {
+		if err := writeArray(out, results, indent); err != nil {
+			return err
+		}
+	}
+
+	// Closing reports any error writing the file.
+	if file, ok := out.(*os.File); ok && output != "" {
+		return file.Close()
 	}
+
+	return nil
+}
+
+// writeArray writes the results as the elements of an array.
+func writeArray(w io.Writer, results [][]byte, indent int) error {
+	b := append([]byte{'['}, bytes.Join(results, []byte{','})...)
+	b = append(b, ']')
+
+	if indent > 0 {
+		buf := bytes.NewBuffer(nil)
+
+		if err := json.Indent(buf, b, "", strings.Repeat(" ", indent)); err != nil {
+			return err
+		}
+
+		b = buf.Bytes()
+	}
+
+	_, err := w.Write(append(b, '\n'))
+
+	return err
+}
+
+// convertFile opens the file at path and calls convert with it. Files with
+// a .gz extension are decompressed, as are all files if gz is set.
+func convertFile(path string, gz bool, convert func(io.Reader) error) error {
+	return flatjson.ConvertFile(path, func(r io.Reader) error {
+		if gz && !flatjson.IsGzipPath(path) {
+			return flatjson.ConvertGzip(r, convert)
+		}
+
+		return convert(r)
+	})
 }
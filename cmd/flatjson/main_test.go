@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles writes the contents to files in a temporary directory and
+// returns their paths.
+func writeFiles(t *testing.T, contents ...string) []string {
+	dir := t.TempDir()
+
+	var paths []string
+
+	for i, c := range contents {
+		path := filepath.Join(dir, string(rune('a'+i))+".json")
+
+		if err := os.WriteFile(path, []byte(c), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+func TestRunArrayFiles(t *testing.T) {
+	paths := writeFiles(t, `{"a": {"b": 1}}`, `[true, "x"]`)
+
+	tests := []struct {
+		Args     []string
+		Expected string
+	}{
+		{[]string{"-array"}, `[[["a.b",1]],[["[0]",true],["[1]","x"]]]`},
+		{[]string{"-array", "-indent", "2"}, "[\n  [\n    [\n      \"a.b\",\n      1\n    ]\n  ],\n  [\n    [\n      \"[0]\",\n      true\n    ],\n    [\n      \"[1]\",\n      \"x\"\n    ]\n  ]\n]"},
+		{nil, "{\"a.b\":1}\n{\"[0]\":true,\"[1]\":\"x\"}"},
+	}
+
+	for _, test := range tests {
+		out := bytes.NewBuffer(nil)
+
+		if err := run(append(test.Args, paths...), nil, out); err != nil {
+			t.Errorf("%v: %s", test.Args, err)
+			continue
+		}
+
+		if s := strings.TrimSpace(out.String()); s != test.Expected {
+			t.Errorf("%v: expected %s, got %s", test.Args, test.Expected, s)
+		}
+	}
+
+	// A single file is written as its own array.
+	out := bytes.NewBuffer(nil)
+
+	if err := run([]string{"-array", paths[0]}, nil, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(out.String()); s != `[["a.b",1]]` {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	if !json.Valid(out.Bytes()) {
+		t.Errorf("expected valid JSON, got %s", out)
+	}
+}
+
+func TestRunRoundTrip(t *testing.T) {
+	input := `{"a":{"b":[]},"c":{},"d":[1,{"e":null}]}`
+
+	tests := []struct {
+		Args     []string
+		Expected string
+	}{
+		{[]string{"-empty-collections"}, input},
+		{nil, `{"a":{"b":null},"c":null,"d":[1,{"e":null}]}`},
+	}
+
+	for _, test := range tests {
+		flat := bytes.NewBuffer(nil)
+
+		if err := run(test.Args, strings.NewReader(input), flat); err != nil {
+			t.Errorf("%v: %s", test.Args, err)
+			continue
+		}
+
+		out := bytes.NewBuffer(nil)
+
+		if err := run([]string{"-unflatten"}, flat, out); err != nil {
+			t.Errorf("%v: %s", test.Args, err)
+			continue
+		}
+
+		if s := strings.TrimSpace(out.String()); s != test.Expected {
+			t.Errorf("%v: expected %s, got %s", test.Args, test.Expected, s)
+		}
+	}
+}
+
+func TestRunGzip(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(buf)
+	zw.Write([]byte(`{"a": [1]}`))
+	zw.Close()
+
+	gz := filepath.Join(t.TempDir(), "a.json.gz")
+
+	if err := os.WriteFile(gz, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Args  []string
+		Stdin []byte
+	}{
+		{[]string{gz}, nil},
+		{[]string{"-gzip", gz}, nil},
+		{[]string{"-gzip"}, buf.Bytes()},
+	}
+
+	for _, test := range tests {
+		out := bytes.NewBuffer(nil)
+
+		if err := run(test.Args, bytes.NewReader(test.Stdin), out); err != nil {
+			t.Errorf("%v: %s", test.Args, err)
+			continue
+		}
+
+		if s := strings.TrimSpace(out.String()); s != `{"a[0]":1}` {
+			t.Errorf("%v: unexpected output %s", test.Args, s)
+		}
+	}
+}
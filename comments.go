@@ -0,0 +1,130 @@
+package flatjson
+
+import (
+	"bufio"
+)
+
+// States of the comment reader.
+const (
+	// Outside of strings and comments.
+	codeState = iota
+
+	// Within a string.
+	stringState
+
+	// After a backslash within a string.
+	escapeState
+
+	// After the slash opening a comment.
+	openState
+
+	// Within a // comment.
+	lineState
+
+	// Within a /* comment.
+	blockState
+
+	// After a star within a /* comment.
+	starState
+)
+
+// commentReader replaces // and /* */ comments with spaces, keeping
+// newlines so offsets and line numbers in errors are unchanged. Slashes
+// within strings are left as is.
+type commentReader struct {
+	r     *bufio.Reader
+	state int
+
+	// Denotes the comment being opened is a // comment.
+	line bool
+}
+
+func (c *commentReader) Read(b []byte) (int, error) {
+	n := 0
+
+	for n < len(b) {
+		// Return what has been read before blocking on the reader.
+		if n > 0 && c.r.Buffered() == 0 {
+			return n, nil
+		}
+
+		ch, err := c.r.ReadByte()
+
+		if err != nil {
+			return n, err
+		}
+
+		b[n] = c.next(ch)
+		n++
+	}
+
+	return n, nil
+}
+
+// next advances the state with the byte and returns the byte to output.
+func (c *commentReader) next(ch byte) byte {
+	switch c.state {
+	case stringState:
+		switch ch {
+		case '\\':
+			c.state = escapeState
+		case '"':
+			c.state = codeState
+		}
+
+		return ch
+
+	case escapeState:
+		c.state = stringState
+		return ch
+
+	case openState:
+		if c.line {
+			c.state = lineState
+		} else {
+			c.state = blockState
+		}
+
+	case lineState:
+		if ch == '\n' {
+			c.state = codeState
+		}
+
+	case blockState, starState:
+		switch {
+		case ch == '/' && c.state == starState:
+			c.state = codeState
+		case ch == '*':
+			c.state = starState
+		default:
+			c.state = blockState
+		}
+
+	default:
+		switch ch {
+		case '"':
+			c.state = stringState
+			return ch
+
+		case '/':
+			// A lone slash is passed through for the decoder to reject.
+			next, err := c.r.Peek(1)
+
+			if err != nil || next[0] != '/' && next[0] != '*' {
+				return ch
+			}
+
+			c.state = openState
+			c.line = next[0] == '/'
+
+		default:
+			return ch
+		}
+	}
+
+	if ch == '\n' {
+		return ch
+	}
+
+	return ' '
+}
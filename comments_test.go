@@ -0,0 +1,78 @@
+package flatjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestAllowComments(t *testing.T) {
+	tests := []jsonTest{
+		{
+			Name:     "line comment",
+			Input:    "{\n  // The name.\n  \"name\": \"Bob\" // Trailing.\n}",
+			Expected: `{"name":"Bob"}`,
+		},
+		{
+			Name:     "block comment",
+			Input:    `{/* a */"a": /* b */ 1 /* c */}`,
+			Expected: `{"a":1}`,
+		},
+		{
+			Name:     "multi-line block comment",
+			Input:    "/**\n * Config.\n **/\n{\"a\": [1, /* two */ 2]}",
+			Expected: `{"a[0]":1,"a[1]":2}`,
+		},
+		{
+			Name:     "slashes in strings",
+			Input:    `{"url": "http://example.com/*x*/", "path": "a//b"}// done`,
+			Expected: `{"path":"a//b","url":"http://example.com/*x*/"}`,
+		},
+		{
+			Name:     "escaped quote in string",
+			Input:    `{"q": "say \"//hi\"" /* c */, "k//": "/*"}`,
+			Expected: `{"k//":"/*","q":"say \"//hi\""}`,
+		},
+		{
+			Name:     "comment adjacent to string",
+			Input:    `{"a"/**/:/**/"b"//c` + "\n}",
+			Expected: `{"a":"b"}`,
+		},
+		{
+			Name:     "comment separating values",
+			Input:    `[1/**/2]`,
+			Expected: ``,
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithAllowComments(true))
+
+		err := enc.ConvertMap(iotest.HalfReader(strings.NewReader(test.Input)))
+
+		// An empty expectation is an error, since a comment is not a comma.
+		if test.Expected == "" {
+			if err == nil {
+				t.Errorf("%s: expected error", test.Name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", test.Name, err)
+			continue
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+
+	// Comments are rejected by default.
+	if _, err := ConvertMap(strings.NewReader(`{"a": 1} // c`)); err == nil {
+		t.Error("expected error for comment")
+	}
+}
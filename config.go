@@ -0,0 +1,149 @@
+package flatjson
+
+import (
+	"io"
+)
+
+// Options is a declarative form of the options that have no functions or
+// arbitrary values, which can itself be encoded, e.g. as JSON. The zero
+// value is the default configuration. Each field corresponds to the Option
+// of the same name, with fields that disable a default behavior negated.
+type Options struct {
+	// Separator between path segments. Empty selects the default, ".".
+	Separator string `json:"separator,omitempty"`
+
+	// Format of array indices and their minimum number of digits.
+	ArrayNotation     ArrayNotation `json:"arrayNotation,omitempty"`
+	IndexWidth        int           `json:"indexWidth,omitempty"`
+	ArrayWildcardKeys bool          `json:"arrayWildcardKeys,omitempty"`
+
+	// Format keys as jq paths, JSONPath expressions or JSON Pointers.
+	JQPaths     bool `json:"jqPaths,omitempty"`
+	JSONPath    bool `json:"jsonPath,omitempty"`
+	JSONPointer bool `json:"jsonPointer,omitempty"`
+
+	// Prefix prepended to every key.
+	Prefix string `json:"prefix,omitempty"`
+
+	// Pattern keys must match to be emitted.
+	KeyFilter string `json:"keyFilter,omitempty"`
+
+	// Do not escape separators, brackets and backslashes in literal keys.
+	NoKeyEscaping bool `json:"noKeyEscaping,omitempty"`
+
+	// Keep nested arrays whole rather than expanding them, or keep them as
+	// arrays of flattened elements.
+	NoFlattenArrays       bool `json:"noFlattenArrays,omitempty"`
+	ArraysAsArrays        bool `json:"arraysAsArrays,omitempty"`
+	UnwrapSingletonArrays bool `json:"unwrapSingletonArrays,omitempty"`
+
+	// Depth at which values are no longer flattened.
+	MaxDepth int `json:"maxDepth,omitempty"`
+
+	// Maximum number of elements flattened per array.
+	MaxArrayExpansion int `json:"maxArrayExpansion,omitempty"`
+
+	// Maximum length of keys in runes.
+	MaxKeyLength int `json:"maxKeyLength,omitempty"`
+
+	// Prefix keys with their nesting depth.
+	DepthPrefix bool `json:"depthPrefix,omitempty"`
+
+	// Reject keys containing control characters.
+	ValidateKeys bool `json:"validateKeys,omitempty"`
+	FieldMask    bool `json:"fieldMask,omitempty"`
+
+	// Handling of empty maps and arrays, containers and values.
+	EmptyCollections     bool `json:"emptyCollections,omitempty"`
+	IncludeContainers    bool `json:"includeContainers,omitempty"`
+	SkipNulls            bool `json:"skipNulls,omitempty"`
+	TrimSpace            bool `json:"trimSpace,omitempty"`
+	NoScientificNotation bool `json:"noScientificNotation,omitempty"`
+
+	// Input handling.
+	RequireObjectRoot    bool  `json:"requireObjectRoot,omitempty"`
+	DuplicateKeysAsArray bool  `json:"duplicateKeysAsArray,omitempty"`
+	AllowComments        bool  `json:"allowComments,omitempty"`
+	MaxPairs             int   `json:"maxPairs,omitempty"`
+	MaxInputBytes        int64 `json:"maxInputBytes,omitempty"`
+	Offsets              bool  `json:"offsets,omitempty"`
+
+	// Output encoding.
+	StrictKeys     bool   `json:"strictKeys,omitempty"`
+	DedupeKeys     bool   `json:"dedupeKeys,omitempty"`
+	SortKeys       bool   `json:"sortKeys,omitempty"`
+	PreserveOrder  bool   `json:"preserveOrder,omitempty"`
+	Indent         string `json:"indent,omitempty"`
+	LineTerminator string `json:"lineTerminator,omitempty"`
+	Root           string `json:"root,omitempty"`
+	TypedValues    bool   `json:"typedValues,omitempty"`
+	StringValues   bool   `json:"stringValues,omitempty"`
+	PathSegments   bool   `json:"pathSegments,omitempty"`
+	MetaHeader     string `json:"metaHeader,omitempty"`
+
+	// Encode array output as objects, with the field names of the key and
+	// value. Empty names select the defaults, "key" and "value".
+	PairObjects bool   `json:"pairObjects,omitempty"`
+	KeyField    string `json:"keyField,omitempty"`
+	ValueField  string `json:"valueField,omitempty"`
+}
+
+// Option returns an Option that applies all of the options, so they can be
+// used with functions such as Parse and combined with other options. Every
+// option is set, overriding those before it.
+func (c Options) Option() Option {
+	return func(o *options) {
+		for _, opt := range []Option{
+			WithSeparator(c.Separator),
+			WithArrayNotation(c.ArrayNotation),
+			WithIndexWidth(c.IndexWidth),
+			WithArrayWildcardKeys(c.ArrayWildcardKeys),
+			WithJQPaths(c.JQPaths),
+			WithJSONPath(c.JSONPath),
+			WithJSONPointer(c.JSONPointer),
+			WithPrefix(c.Prefix),
+			WithKeyFilter(c.KeyFilter),
+			WithKeyEscaping(!c.NoKeyEscaping),
+			WithFlattenArrays(!c.NoFlattenArrays),
+			WithArraysAsArrays(c.ArraysAsArrays),
+			WithUnwrapSingletonArrays(c.UnwrapSingletonArrays),
+			WithMaxDepth(c.MaxDepth),
+			WithMaxArrayExpansion(c.MaxArrayExpansion),
+			WithMaxKeyLength(c.MaxKeyLength),
+			WithDepthPrefix(c.DepthPrefix),
+			WithValidateKeys(c.ValidateKeys),
+			WithFieldMask(c.FieldMask),
+			WithEmptyCollections(c.EmptyCollections),
+			WithIncludeContainers(c.IncludeContainers),
+			WithSkipNulls(c.SkipNulls),
+			WithTrimSpace(c.TrimSpace),
+			WithNoScientificNotation(c.NoScientificNotation),
+			WithRequireObjectRoot(c.RequireObjectRoot),
+			WithDuplicateKeysAsArray(c.DuplicateKeysAsArray),
+			WithAllowComments(c.AllowComments),
+			WithMaxPairs(c.MaxPairs),
+			WithMaxInputBytes(c.MaxInputBytes),
+			WithOffsets(c.Offsets),
+			WithStrictKeys(c.StrictKeys),
+			WithDedupeKeys(c.DedupeKeys),
+			WithSortKeys(c.SortKeys),
+			WithPreserveOrder(c.PreserveOrder),
+			WithIndent(c.Indent),
+			WithLineTerminator(c.LineTerminator),
+			WithRoot(c.Root),
+			WithTypedValues(c.TypedValues),
+			WithStringValues(c.StringValues),
+			WithPathSegments(c.PathSegments),
+			WithMetaHeader(c.MetaHeader),
+			WithPairObjects(c.PairObjects, c.KeyField, c.ValueField),
+		} {
+			opt(o)
+		}
+	}
+}
+
+// NewEncoderWith initializes a new Encoder for the writer configured by
+// the options.
+func NewEncoderWith(w io.Writer, opts Options) *Encoder {
+	return NewEncoder(w, opts.Option())
+}
@@ -0,0 +1,76 @@
+package flatjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewEncoderWith(t *testing.T) {
+	input := `{"b": {"c": [1, null]}, "a": "x"}`
+
+	tests := []struct {
+		Options  Options
+		Expected string
+	}{
+		{Options{}, `{"a":"x","b.c[0]":1,"b.c[1]":null}`},
+		{Options{Separator: "/", ArrayNotation: PlainNotation}, `{"a":"x","b/c/0":1,"b/c/1":null}`},
+		{Options{SkipNulls: true, Prefix: "p"}, `{"p.a":"x","p.b.c[0]":1}`},
+		{Options{MaxDepth: 1, PreserveOrder: true}, `{"b":{"c":[1,null]},"a":"x"}`},
+		{Options{NoFlattenArrays: true, KeyFilter: "b.*"}, `{"b.c":[1,null]}`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoderWith(buf, test.Options)
+
+		if err := enc.ConvertMap(strings.NewReader(input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%+v: expected %s, got %s", test.Options, test.Expected, s)
+		}
+	}
+
+	// The zero value is the default configuration. Functions are not
+	// comparable, so the default key shortener is left out.
+	o, def := newOptions([]Option{Options{}.Option()}), newOptions(nil)
+	o.shortenKey, def.shortenKey = nil, nil
+
+	if !reflect.DeepEqual(o, def) {
+		t.Errorf("expected default options, got %+v", o)
+	}
+}
+
+func TestOptionsJSON(t *testing.T) {
+	opts := Options{Separator: "/", ArrayNotation: DottedBracketNotation, SkipNulls: true}
+
+	b, err := json.Marshal(opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"separator":"/","arrayNotation":"dotted","skipNulls":true}`
+
+	if string(b) != exp {
+		t.Errorf("expected %s, got %s", exp, b)
+	}
+
+	var dec Options
+
+	if err := json.Unmarshal(b, &dec); err != nil {
+		t.Fatal(err)
+	}
+
+	if dec != opts {
+		t.Errorf("expected %+v, got %+v", opts, dec)
+	}
+
+	if err := json.Unmarshal([]byte(`{"arrayNotation":"curly"}`), &dec); err == nil {
+		t.Error("expected an error for an unknown notation")
+	}
+}
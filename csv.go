@@ -0,0 +1,61 @@
+package flatjson
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+)
+
+// EncodeCSV encodes a value as a two-column CSV of keys and JSON-encoded
+// values, preceded by a key,value header.
+func (f *Encoder) EncodeCSV(v interface{}) error {
+	pairs, err := parseValueOf(v, &f.opts)
+
+	if err != nil {
+		return err
+	}
+
+	return f.writeCSV(pairs)
+}
+
+// ConvertCSV re-encodes a JSON value as a two-column CSV of keys and
+// JSON-encoded values, preceded by a key,value header.
+func (f *Encoder) ConvertCSV(r io.Reader) error {
+	pairs, err := parseJSON(context.Background(), r, &f.opts)
+
+	if err != nil {
+		return err
+	}
+
+	return f.writeCSV(pairs)
+}
+
+// writeCSV writes the pairs as CSV records.
+func (f *Encoder) writeCSV(pairs []*Pair) error {
+	if f.opts.sortKeys {
+		sortPairs(pairs)
+	}
+
+	w := csv.NewWriter(f.w)
+
+	if err := w.Write([]string{"key", "value"}); err != nil {
+		return err
+	}
+
+	for _, p := range f.opts.outputPairs(pairs) {
+		b, err := json.Marshal(p.Value)
+
+		if err != nil {
+			return err
+		}
+
+		if err := w.Write([]string{p.Key, string(b)}); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
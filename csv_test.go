@@ -0,0 +1,68 @@
+package flatjson
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeCSV(t *testing.T) {
+	v := map[string]interface{}{
+		"name": `Smith, "Bob"`,
+		"address": map[string]interface{}{
+			"zipcode": 13943,
+		},
+		"active": true,
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithSortKeys(true))
+
+	if err := enc.EncodeCSV(v); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(buf).ReadAll()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"key", "value"},
+		{"active", "true"},
+		{"address.zipcode", "13943"},
+		{"name", `"Smith, \"Bob\""`},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestConvertCSVStringValues(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithStringValues(true))
+
+	if err := enc.ConvertCSV(strings.NewReader(`{"a": 1, "b": null}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(buf).ReadAll()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"key", "value"},
+		{"a", `"1"`},
+		{"b", `""`},
+	}
+
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
@@ -0,0 +1,58 @@
+package flatjson
+
+import (
+	"context"
+	"io"
+)
+
+// Decoder reads flattened key-value pairs from a JSON input one at a time.
+type Decoder struct {
+	p    *parser
+	opts options
+}
+
+// NewDecoder initializes a new Decoder for the reader.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{
+		opts: newOptions(opts),
+	}
+
+	d.p = newReaderParser(context.Background(), r, &d.opts)
+
+	return d
+}
+
+// Next returns the next key-value pair. It returns io.EOF once the input
+// has been exhausted.
+func (d *Decoder) Next() (*Pair, error) {
+	for {
+		pair, err := d.p.next()
+
+		// Continue with the next top-level value, if any.
+		if err == io.EOF && d.p.started {
+			d.p.reset()
+			continue
+		}
+
+		return pair, err
+	}
+}
+
+// All returns an iterator over the remaining pairs that can be used with
+// range-over-func. Iteration stops after the first error, which is yielded
+// with a nil pair.
+func (d *Decoder) All() func(yield func(*Pair, error) bool) {
+	return func(yield func(*Pair, error) bool) {
+		for {
+			pair, err := d.Next()
+
+			if err == io.EOF {
+				return
+			}
+
+			if !yield(pair, err) || err != nil {
+				return
+			}
+		}
+	}
+}
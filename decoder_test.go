@@ -0,0 +1,54 @@
+package flatjson
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a": 1, "b": {"c": true}} {"d": "x"}`))
+
+	var keys []string
+
+	for {
+		p, err := dec.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys = append(keys, p.Key)
+	}
+
+	if strings.Join(keys, ",") != "a,b.c,d" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after exhaustion, got %v", err)
+	}
+}
+
+func TestDecoderAll(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"a": 1, "b": [`))
+
+	var (
+		n    int
+		last error
+	)
+
+	dec.All()(func(p *Pair, err error) bool {
+		n++
+		last = err
+		return true
+	})
+
+	if n != 2 || last == nil {
+		t.Errorf("expected one pair and an error, got %d values and %v", n, last)
+	}
+}
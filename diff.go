@@ -0,0 +1,285 @@
+package flatjson
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// ChangeType is the way the value of a key differs between two documents.
+type ChangeType int
+
+const (
+	// The key is only in the new document.
+	Added ChangeType = iota
+
+	// The key is only in the old document.
+	Removed
+
+	// The key has different values in the documents.
+	Modified
+)
+
+var changeTypeNames = map[ChangeType]string{
+	Added:    "added",
+	Removed:  "removed",
+	Modified: "modified",
+}
+
+func (t ChangeType) String() string {
+	if name, ok := changeTypeNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+// MarshalText encodes the change type as its name.
+func (t ChangeType) MarshalText() ([]byte, error) {
+	if _, ok := changeTypeNames[t]; !ok {
+		return nil, fmt.Errorf("flatjson: unknown change type %d", int(t))
+	}
+
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes the change type from its name.
+func (t *ChangeType) UnmarshalText(b []byte) error {
+	for ct, name := range changeTypeNames {
+		if name == string(b) {
+			*t = ct
+			return nil
+		}
+	}
+
+	return fmt.Errorf("flatjson: unknown change type %q", b)
+}
+
+// Change is a difference in the value of a flattened key between two
+// documents. Old is nil for an added key and New is nil for a removed one.
+type Change struct {
+	Type ChangeType  `json:"type"`
+	Key  string      `json:"key"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// Diff flattens two JSON documents with the options and returns the keys
+// that were added, removed or modified going from a to b, sorted by key.
+// Numbers are compared by value, so 1 and 1.0 are equal. If a document has
+// duplicate keys, the last value is compared, as in map output. Empty maps
+// and arrays are kept as with WithEmptyCollections(true), so changes to
+// them can be told apart from null values and patched.
+func Diff(a, b io.Reader, opts ...Option) ([]Change, error) {
+	o := newOptions(opts)
+	o.keepEmpty = true
+
+	old, err := parseJSON(context.Background(), a, &o)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := parseJSON(context.Background(), b, &o)
+
+	if err != nil {
+		return nil, err
+	}
+
+	om := mapPairs(old).toMap()
+	nm := mapPairs(cur).toMap()
+
+	var changes []Change
+
+	for k, ov := range om {
+		nv, ok := nm[k]
+
+		if !ok {
+			changes = append(changes, Change{Type: Removed, Key: k, Old: ov})
+		} else if !valuesEqual(ov, nv) {
+			changes = append(changes, Change{Type: Modified, Key: k, Old: ov, New: nv})
+		}
+	}
+
+	for k, nv := range nm {
+		if _, ok := om[k]; !ok {
+			changes = append(changes, Change{Type: Added, Key: k, New: nv})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes, nil
+}
+
+// Patch applies changes, such as those returned by Diff, to a JSON
+// document and returns the patched document. The document is flattened with
+// the options, the changes are applied to its keys and the keys are
+// unflattened as by Unflatten, so the options should match those the
+// changes were made with.
+//
+// An added key must not already exist, and a removed or modified key must
+// exist with the old value of the change, otherwise the change conflicts
+// with the document and an error is returned. So is an error for keys
+// that no longer fit together, such as a key added within a value that is
+// not a map. Removing array elements other than the last leaves nulls in
+// their place.
+//
+// As in Diff, empty maps and arrays are kept, so parts of the document the
+// changes do not touch are written unchanged. A top-level scalar stays a
+// scalar unless keys are added to it.
+func Patch(base io.Reader, changes []Change, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	o.keepEmpty = true
+
+	var pairs []*Pair
+
+	p := newReaderParser(context.Background(), base, &o)
+
+	for {
+		err := parseValue(p, func(pair *Pair) error {
+			pairs = append(pairs, pair)
+			return nil
+		})
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	flat := mapPairs(pairs).toMap()
+
+	for _, c := range changes {
+		v, ok := flat[c.Key]
+
+		switch c.Type {
+		case Added:
+			if ok {
+				return nil, fmt.Errorf("flatjson: cannot add key %q: it already exists", c.Key)
+			}
+
+			flat[c.Key] = c.New
+
+		case Removed, Modified:
+			if !ok {
+				return nil, fmt.Errorf("flatjson: cannot apply %s key %q: it does not exist", c.Type, c.Key)
+			}
+
+			if !valuesEqual(v, c.Old) {
+				return nil, fmt.Errorf("flatjson: cannot apply %s key %q: expected old value %v, got %v", c.Type, c.Key, c.Old, v)
+			}
+
+			if c.Type == Removed {
+				delete(flat, c.Key)
+			} else {
+				flat[c.Key] = c.New
+			}
+
+		default:
+			return nil, fmt.Errorf("flatjson: unknown change type %d for key %q", int(c.Type), c.Key)
+		}
+	}
+
+	v, err := patchedValue(flat, p.root, &o)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := &Encoder{w: buf, opts: o}
+
+	if err := enc.jsonEncoder().Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// patchedValue reconstructs the patched document from its keys. A document
+// that is still only the top-level scalar or empty array it was is not
+// unflattened, which would turn it into a map.
+func patchedValue(flat map[string]interface{}, root Kind, o *options) (interface{}, error) {
+	switch root {
+	case ObjectKind:
+	case ArrayKind:
+		if len(flat) == 0 {
+			return []interface{}{}, nil
+		}
+	default:
+		if v, ok := flat[o.prefixKey("")]; ok && len(flat) == 1 && o.prefix == "" {
+			return v, nil
+		}
+	}
+
+	return unflatten(flat, o)
+}
+
+// valuesEqual returns true if two flattened values are equal, comparing
+// numbers, including those in maps and arrays kept whole, by value.
+// Numbers need not be json.Number, such as in changes decoded as float64.
+func valuesEqual(a, b interface{}) bool {
+	if kindOf(a) == NumberKind {
+		x, _ := (&Pair{Value: a}).Number()
+		y, ok := (&Pair{Value: b}).Number()
+
+		if !ok {
+			return false
+		}
+
+		if x == y {
+			return true
+		}
+
+		fx, _, errx := big.ParseFloat(string(x), 10, 512, big.ToNearestEven)
+		fy, _, erry := big.ParseFloat(string(y), 10, 512, big.ToNearestEven)
+
+		return errx == nil && erry == nil && fx.Cmp(fy) == 0
+	}
+
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+
+		if !ok || len(x) != len(y) {
+			return false
+		}
+
+		for k, v := range x {
+			w, ok := y[k]
+
+			if !ok || !valuesEqual(v, w) {
+				return false
+			}
+		}
+
+		return true
+
+	case []interface{}:
+		y, ok := b.([]interface{})
+
+		if !ok || len(x) != len(y) {
+			return false
+		}
+
+		for i := range x {
+			if !valuesEqual(x[i], y[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,183 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := `{"name": "Bob", "age": 30, "tags": ["a", "b"], "address": {"city": "Boresville", "zip": null}, "score": 1.0}`
+	b := `{"name": "Bob", "age": 31, "tags": ["a"], "address": {"city": "Boresville", "geo": [1.5]}, "score": 1}`
+
+	changes, err := Diff(strings.NewReader(a), strings.NewReader(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Change{
+		{Added, "address.geo[0]", nil, json.Number("1.5")},
+		{Removed, "address.zip", nil, nil},
+		{Modified, "age", json.Number("30"), json.Number("31")},
+		{Removed, "tags[1]", "b", nil},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+
+	// Values kept whole are compared in full.
+	changes, err = Diff(strings.NewReader(`{"a": [1, {"b": 2.0}]}`), strings.NewReader(`{"a": [1, {"b": 2}], "c": {}}`), WithFlattenArrays(false), WithEmptyCollections(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []Change{{Added, "c", nil, map[string]interface{}{}}}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %v, got %v", expected, changes)
+	}
+
+	if _, err := Diff(strings.NewReader(`{}`), strings.NewReader(`{`)); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestChangeJSON(t *testing.T) {
+	b, err := json.Marshal(Change{Type: Modified, Key: "a", Old: 1, New: "x"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"type":"modified","key":"a","old":1,"new":"x"}`
+
+	if string(b) != expected {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+
+	var c Change
+
+	if err := json.Unmarshal([]byte(`{"type":"removed","key":"a","old":true}`), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Type != Removed || c.Key != "a" || c.Old != true {
+		t.Errorf("unexpected change %+v", c)
+	}
+
+	if err := json.Unmarshal([]byte(`{"type":"renamed"}`), &c); err == nil {
+		t.Error("expected an error for an unknown change type")
+	}
+}
+
+func TestPatch(t *testing.T) {
+	a := `{"name": "Bob", "age": 30, "tags": ["a", "b"], "address": {"city": "Boresville", "zip": null}}`
+	b := `{"name": "Bob", "age": 31, "tags": ["a"], "address": {"city": "Boresville", "geo": [1.5]}, "x.y": true}`
+
+	changes, err := Diff(strings.NewReader(a), strings.NewReader(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Patch(strings.NewReader(a), changes)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"address":{"city":"Boresville","geo":[1.5]},"age":31,"name":"Bob","tags":["a"],"x.y":true}`
+
+	if s := strings.TrimSpace(string(out)); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	// Changes decoded from JSON have float64 numbers.
+	var decoded []Change
+
+	if err := json.Unmarshal([]byte(`[{"type": "modified", "key": "/a/0", "old": 1.0, "new": 2}]`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = Patch(strings.NewReader(`{"a": [1]}`), decoded, WithJSONPointer(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(out)); s != `{"a":[2]}` {
+		t.Errorf("unexpected document %s", s)
+	}
+
+	// Parts of the document the changes do not touch are kept as they are.
+	documents := []struct {
+		Base     string
+		Changes  []Change
+		Expected string
+	}{
+		{`{"x": [], "o": {}, "y": 1}`, []Change{{Type: Modified, Key: "y", Old: 1, New: 2}}, `{"o":{},"x":[],"y":2}`},
+		{`{"x": []}`, []Change{{Type: Removed, Key: "x", Old: []interface{}{}}, {Type: Added, Key: "x[0]", New: 1}}, `{"x":[1]}`},
+		{`"s"`, nil, `"s"`},
+		{`"s"`, []Change{{Type: Modified, Key: "", Old: "s", New: "t"}}, `"t"`},
+		{`"s"`, []Change{{Type: Removed, Key: "", Old: "s"}, {Type: Added, Key: "a", New: 1}}, `{"a":1}`},
+		{`[]`, nil, `[]`},
+	}
+
+	for _, test := range documents {
+		out, err := Patch(strings.NewReader(test.Base), test.Changes)
+
+		if err != nil {
+			t.Errorf("%s: %s", test.Base, err)
+			continue
+		}
+
+		if s := strings.TrimSpace(string(out)); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Base, test.Expected, s)
+		}
+	}
+
+	// Changes to empty collections found by Diff apply cleanly.
+	a, b = `{"x": [], "o": {"p": 1}}`, `{"x": [1], "o": {}}`
+
+	changes, err = Diff(strings.NewReader(a), strings.NewReader(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = Patch(strings.NewReader(a), changes)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(out)); s != `{"o":{},"x":[1]}` {
+		t.Errorf("unexpected document %s", s)
+	}
+
+	conflicts := []struct {
+		Base    string
+		Changes []Change
+		Error   string
+	}{
+		{`{"a": 1}`, []Change{{Type: Added, Key: "a", New: 2}}, `cannot add key "a": it already exists`},
+		{`{"a": 1}`, []Change{{Type: Removed, Key: "b"}}, `cannot apply removed key "b": it does not exist`},
+		{`{"a": 1}`, []Change{{Type: Modified, Key: "a", Old: 2, New: 3}}, `cannot apply modified key "a": expected old value 2, got 1`},
+		{`{"a": 1}`, []Change{{Type: Added, Key: "a.b", New: 2}}, `key "a.b" descends into a non-object value`},
+		{`{"a": {"b": 1}}`, []Change{{Type: Added, Key: "a[0]", New: 2}}, `key "a[0]" indexes a non-array value`},
+		{`{"a": 1}`, []Change{{Type: ChangeType(9), Key: "a"}}, `unknown change type 9`},
+		{`{"a": 1}`, []Change{{Type: Added, Key: "b[99999999999]", New: 2}}, `array index 99999999999 out of range`},
+	}
+
+	for _, test := range conflicts {
+		_, err := Patch(strings.NewReader(test.Base), test.Changes)
+
+		if err == nil || !strings.Contains(err.Error(), test.Error) {
+			t.Errorf("%s: expected error %q, got %v", test.Base, test.Error, err)
+		}
+	}
+}
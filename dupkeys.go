@@ -0,0 +1,164 @@
+package flatjson
+
+import (
+	"encoding/json"
+)
+
+// WithDuplicateKeysAsArray sets whether the values of a key repeated in
+// the same map are collected into an array in the order they appear, so
+// {"a": 1, "a": 2} is flattened to "a[0]" and "a[1]" rather than keeping
+// only the last value. The array takes the place of the first occurrence of
+// the key. Each top-level value is read in full before it is flattened, and
+// offsets set by WithOffsets are not recorded.
+func WithDuplicateKeysAsArray(collect bool) Option {
+	return func(o *options) {
+		o.duplicateKeys = collect
+	}
+}
+
+// tokenReader returns the reader of the decoder's tokens for the parser.
+func (o *options) tokenReader(dec *json.Decoder) tokenReader {
+	if o.duplicateKeys {
+		return &dupKeyReader{dec: dec}
+	}
+
+	return dec
+}
+
+// dupKeyReader reads top-level values from a decoder and replays their
+// tokens with the values of duplicate map keys collected into arrays.
+type dupKeyReader struct {
+	dec *json.Decoder
+	tokenSlice
+
+	// Error reading the current value, returned once the tokens read
+	// before it are replayed as they appeared.
+	err error
+}
+
+func (d *dupKeyReader) Token() (json.Token, error) {
+	if d.i < len(d.toks) {
+		return d.tokenSlice.Token()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var raw []json.Token
+
+	toks, err := readGrouped(d.dec, &raw)
+
+	if err != nil {
+		d.toks, d.err = raw, err
+	} else {
+		d.toks = toks
+	}
+
+	d.i = 0
+
+	return d.Token()
+}
+
+func (d *dupKeyReader) More() bool {
+	if d.i < len(d.toks) {
+		return d.tokenSlice.More()
+	}
+
+	return d.dec.More()
+}
+
+func (d *dupKeyReader) InputOffset() int64 {
+	return d.dec.InputOffset()
+}
+
+// groupedMember is a key of a map and each of its values.
+type groupedMember struct {
+	key  string
+	vals [][]json.Token
+}
+
+// readGrouped reads the tokens of the next value, grouping the values of
+// duplicate map keys into arrays. Each token read is appended to raw.
+func readGrouped(dec *json.Decoder, raw *[]json.Token) ([]json.Token, error) {
+	tok, err := dec.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	*raw = append(*raw, tok)
+
+	if tok != lbrace && tok != lsquare {
+		return []json.Token{tok}, nil
+	}
+
+	var (
+		toks    = []json.Token{tok}
+		members []groupedMember
+		index   map[string]int
+	)
+
+	if tok == lbrace {
+		index = make(map[string]int)
+	}
+
+	for dec.More() {
+		var key string
+
+		// The decoder only returns strings as map keys.
+		if index != nil {
+			tok, err := dec.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			*raw = append(*raw, tok)
+			key, _ = tok.(string)
+		}
+
+		v, err := readGrouped(dec, raw)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if index == nil {
+			toks = append(toks, v...)
+		} else if i, ok := index[key]; ok {
+			members[i].vals = append(members[i].vals, v)
+		} else {
+			index[key] = len(members)
+			members = append(members, groupedMember{key: key, vals: [][]json.Token{v}})
+		}
+	}
+
+	// Consume the closing delimiter.
+	end, err := dec.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	*raw = append(*raw, end)
+
+	for _, m := range members {
+		toks = append(toks, m.key)
+
+		if len(m.vals) == 1 {
+			toks = append(toks, m.vals[0]...)
+			continue
+		}
+
+		toks = append(toks, lsquare)
+
+		for _, v := range m.vals {
+			toks = append(toks, v...)
+		}
+
+		toks = append(toks, rsquare)
+	}
+
+	return append(toks, end), nil
+}
@@ -0,0 +1,71 @@
+package flatjson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateKeysAsArray(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a": 1, "b": 2, "a": 3}`, nil, `[["a[0]",1],["a[1]",3],["b",2]]`},
+		{`{"a": {"x": 1}, "a": [2, 3], "a": null}`, nil, `[["a[0].x",1],["a[1][0]",2],["a[1][1]",3],["a[2]",null]]`},
+		{`[{"a": 1, "a": 2}, {"a": 3}]`, nil, `[["[0].a[0]",1],["[0].a[1]",2],["[1].a",3]]`},
+		{`{"a": {"b": 1, "b": 2}} {"c": 1, "c": 2}`, nil, `[["a.b[0]",1],["a.b[1]",2],["c[0]",1],["c[1]",2]]`},
+		{`{"a": 1, "a": 2}`, []Option{WithFlattenArrays(false)}, `[["a",[1,2]]]`},
+		{`{"a": 1, "a": 2}`, []Option{WithMaxDepth(1)}, `[["a",[1,2]]]`},
+		{`{"a": 1, "a": 2}`, []Option{WithDuplicateKeysAsArray(false)}, `[["a",1],["a",2]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append([]Option{WithDuplicateKeysAsArray(true)}, test.Options...)...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestDuplicateKeysAsArrayErrors(t *testing.T) {
+	// Tokens before an error are flattened as they appear.
+	var keys []string
+
+	err := ParseFunc(strings.NewReader(`{"a": 1, "a": 2, "b": x}`), func(p *Pair) error {
+		keys = append(keys, p.Key)
+		return nil
+	}, WithDuplicateKeysAsArray(true))
+
+	var perr *ParseError
+
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a parse error, got %v", err)
+	}
+
+	if perr.Path != "b" || strings.Join(keys, ",") != "a,a" {
+		t.Errorf("unexpected path %q and keys %v", perr.Path, keys)
+	}
+
+	_, err = Parse(strings.NewReader(`{"a": 1} junk`), WithDuplicateKeysAsArray(true))
+
+	var terr *TrailingDataError
+
+	if !errors.As(err, &terr) {
+		t.Errorf("expected trailing data error, got %v", err)
+	}
+
+	_, err = Parse(strings.NewReader(`{"a": [1, 2`), WithDuplicateKeysAsArray(true))
+
+	if !errors.As(err, &perr) || perr.Path != "a[1]" {
+		t.Errorf("expected a parse error at a[1], got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package flatjson
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrLimitExceeded is returned when the input exceeds a limit set by
+// WithMaxPairs or WithMaxInputBytes.
+var ErrLimitExceeded = errors.New("flatjson: limit exceeded")
+
+// ErrUnbalanced is returned, wrapped in a ParseError, when a token stream
+// closes a map or array that is not open. The JSON decoder rejects such
+// input itself, so this guards against other sources of tokens.
+var ErrUnbalanced = errors.New("flatjson: unbalanced JSON")
+
+// ParseError is returned when the input is not valid JSON. It records
+// where in the input the error occurred and the flattened path that was
+// being built at the time.
+type ParseError struct {
+	// Byte offset in the input.
+	Offset int64
+
+	// Flattened path being built.
+	Path string
+
+	// Underlying decoder error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("flatjson: invalid token at offset %d (path %q): %s", e.Offset, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying decoder error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError wraps a decoder error with its position. The input is
+// known to be incomplete, so io.EOF is reported as io.ErrUnexpectedEOF.
+// Exceeded limits are not parse errors and are returned as is.
+func newParseError(dec tokenReader, path string, err error) error {
+	if errors.Is(err, ErrLimitExceeded) {
+		return err
+	}
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return &ParseError{
+		Offset: dec.InputOffset(),
+		Path:   path,
+		Err:    err,
+	}
+}
+
+// TrailingDataError is returned when input following a complete value is
+// not another JSON value, such as `{"a": 1} junk`.
+type TrailingDataError struct {
+	// Byte offset in the input of the end of the last complete value.
+	Offset int64
+
+	// Underlying decoder error.
+	Err error
+}
+
+func (e *TrailingDataError) Error() string {
+	return fmt.Sprintf("flatjson: unexpected trailing data at offset %d", e.Offset)
+}
+
+// Unwrap returns the underlying decoder error.
+func (e *TrailingDataError) Unwrap() error {
+	return e.Err
+}
+
+// newTrailingDataError wraps a decoder error that occurred after a value.
+func newTrailingDataError(dec tokenReader, err error) error {
+	return &TrailingDataError{
+		Offset: dec.InputOffset(),
+		Err:    err,
+	}
+}
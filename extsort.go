@@ -0,0 +1,312 @@
+package flatjson
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Number of pairs SortedConvertMap sorts in memory before spilling them to
+// a temporary file.
+var sortChunkSize = 100000
+
+// sortedPair is a pair with its value encoded.
+type sortedPair struct {
+	key   string
+	value json.RawMessage
+}
+
+// chunkReader reads a sorted chunk of pairs, either held in memory or
+// spilled to a file.
+type chunkReader struct {
+	pairs []sortedPair
+	dec   *json.Decoder
+
+	// Current pair and the order of the chunk in the input, which breaks
+	// ties between equal keys.
+	cur sortedPair
+	idx int
+}
+
+// next advances to the next pair. It returns false once the chunk is
+// exhausted.
+func (c *chunkReader) next() (bool, error) {
+	if c.dec == nil {
+		if len(c.pairs) == 0 {
+			return false, nil
+		}
+
+		c.cur, c.pairs = c.pairs[0], c.pairs[1:]
+
+		return true, nil
+	}
+
+	var rec [2]json.RawMessage
+
+	if err := c.dec.Decode(&rec); err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	c.cur.value = rec[1]
+
+	return true, json.Unmarshal(rec[0], &c.cur.key)
+}
+
+// mergeHeap orders chunk readers by their current key.
+type mergeHeap []*chunkReader
+
+func (h mergeHeap) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].cur.key != h[j].cur.key {
+		return h[i].cur.key < h[j].cur.key
+	}
+
+	return h[i].idx < h[j].idx
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*chunkReader))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// SortedConvertMap re-encodes a JSON value into a flat map with sorted
+// keys like ConvertMap with WithSortKeys, without holding every pair in
+// memory. Pairs are sorted in chunks that are spilled to temporary files
+// in tmpDir, or the default directory for temporary files if it is empty,
+// and merged as the output is written. The files are removed before
+// returning.
+//
+// As with ConvertMap, the last value of a duplicate key is written unless
+// WithStrictKeys is set, in which case an error is returned, possibly after
+// part of the output is written. WithPreserveOrder, WithDedupeKeys,
+// WithRoot and WithMetaHeader are not supported and are ignored.
+func (f *Encoder) SortedConvertMap(r io.Reader, tmpDir string) error {
+	var (
+		chunk []sortedPair
+		files []*os.File
+	)
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	err := parseFunc(context.Background(), r, &f.opts, func(p *Pair) error {
+		p = f.opts.outputPair(p)
+
+		b, err := json.Marshal(p.Value)
+
+		if err != nil {
+			return err
+		}
+
+		chunk = append(chunk, sortedPair{key: p.Key, value: b})
+
+		if len(chunk) < sortChunkSize {
+			return nil
+		}
+
+		file, err := os.CreateTemp(tmpDir, "flatjson-*")
+
+		if err != nil {
+			return err
+		}
+
+		files = append(files, file)
+		sortChunk(chunk)
+
+		if err := spillChunk(file, chunk); err != nil {
+			return err
+		}
+
+		chunk = chunk[:0]
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sortChunk(chunk)
+
+	// Spilled chunks precede the last chunk in the input.
+	readers := make([]*chunkReader, 0, len(files)+1)
+
+	for _, file := range files {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		dec := json.NewDecoder(bufio.NewReader(file))
+		dec.UseNumber()
+
+		readers = append(readers, &chunkReader{dec: dec})
+	}
+
+	readers = append(readers, &chunkReader{pairs: chunk})
+
+	return f.mergeMap(readers)
+}
+
+// sortChunk sorts pairs by key, keeping pairs with the same key in order.
+func sortChunk(chunk []sortedPair) {
+	sort.SliceStable(chunk, func(i, j int) bool {
+		return chunk[i].key < chunk[j].key
+	})
+}
+
+// spillChunk writes the pairs to the file, one [key, value] array per line.
+func spillChunk(file *os.File, chunk []sortedPair) error {
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+
+	for _, p := range chunk {
+		if err := enc.Encode(tokArray{p.key, p.value}); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// mergeMap merges the sorted chunks and writes them as a flat map,
+// formatted as json.Encoder formats a map.
+func (f *Encoder) mergeMap(readers []*chunkReader) error {
+	h := make(mergeHeap, 0, len(readers))
+
+	for i, c := range readers {
+		c.idx = i
+
+		ok, err := c.next()
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			h = append(h, c)
+		}
+	}
+
+	heap.Init(&h)
+
+	var (
+		w      = bufio.NewWriter(f.w)
+		indent = f.opts.indent
+		buf    bytes.Buffer
+		n      int
+	)
+
+	write := func(p sortedPair) error {
+		if n == 0 {
+			w.WriteByte('{')
+		} else {
+			w.WriteByte(',')
+		}
+
+		if indent != "" {
+			w.WriteString("\n" + indent)
+		}
+
+		k, err := json.Marshal(p.key)
+
+		if err != nil {
+			return err
+		}
+
+		w.Write(k)
+		w.WriteByte(':')
+
+		if indent != "" {
+			w.WriteByte(' ')
+			buf.Reset()
+			json.Indent(&buf, p.value, indent, indent)
+			w.Write(buf.Bytes())
+		} else {
+			w.Write(p.value)
+		}
+
+		n++
+
+		return nil
+	}
+
+	// The last value of a key is written once the next key is seen.
+	var pending *sortedPair
+
+	for h.Len() > 0 {
+		c := h[0]
+		p := c.cur
+
+		ok, err := c.next()
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+
+		if pending != nil && pending.key == p.key {
+			if f.opts.strictKeys {
+				return fmt.Errorf("flatjson: duplicate key %q", p.key)
+			}
+		} else if pending != nil {
+			if err := write(*pending); err != nil {
+				return err
+			}
+		}
+
+		pending = &p
+	}
+
+	if pending != nil {
+		if err := write(*pending); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case n == 0:
+		w.WriteString("{}")
+	case indent != "":
+		w.WriteString("\n}")
+	default:
+		w.WriteByte('}')
+	}
+
+	if f.opts.newline == "" {
+		w.WriteByte('\n')
+	} else {
+		w.WriteString(f.opts.newline)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,84 @@
+package flatjson
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSortedConvertMap(t *testing.T) {
+	defer func(n int) {
+		sortChunkSize = n
+	}(sortChunkSize)
+
+	sortChunkSize = 3
+
+	tests := map[string][]Option{
+		"default":   nil,
+		"indent":    {WithIndent("  ")},
+		"depth":     {WithMaxDepth(2), WithIndent("\t")},
+		"typed":     {WithTypedValues(true)},
+		"separator": {WithSeparator("/"), WithLineTerminator("\r\n")},
+	}
+
+	for name, opts := range tests {
+		var expected, got bytes.Buffer
+
+		if err := NewEncoder(&expected, append(opts, WithSortKeys(true))...).ConvertMap(strings.NewReader(benchDoc)); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+
+		if err := NewEncoder(&got, opts...).SortedConvertMap(strings.NewReader(benchDoc), dir); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if got.String() != expected.String() {
+			t.Errorf("%s: expected %s, got %s", name, expected.String(), got.String())
+		}
+
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("%s: expected temporary files to be removed, got %d", name, len(files))
+		}
+	}
+}
+
+func TestSortedConvertMapDuplicates(t *testing.T) {
+	defer func(n int) {
+		sortChunkSize = n
+	}(sortChunkSize)
+
+	sortChunkSize = 2
+
+	input := `{"b": 1, "a": {"b": 1}, "a": {"b": 2}, "c": 3, "a": {"b": 3}, "b": 2}`
+
+	var buf bytes.Buffer
+
+	if err := NewEncoder(&buf).SortedConvertMap(strings.NewReader(input), t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\"a.b\":3,\"b\":2,\"c\":3}\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %s, got %s", expected, buf.String())
+	}
+
+	err := NewEncoder(&buf, WithStrictKeys(true)).SortedConvertMap(strings.NewReader(input), t.TempDir())
+
+	if err == nil {
+		t.Error("expected an error for duplicate keys")
+	}
+
+	buf.Reset()
+
+	if err := NewEncoder(&buf).SortedConvertMap(strings.NewReader(`[]`), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "{}\n" {
+		t.Errorf("expected an empty map, got %s", buf.String())
+	}
+}
@@ -0,0 +1,44 @@
+package flatjson
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithFieldMask sets whether flattening stops with an error naming the key
+// if a key is not a valid protocol buffers FieldMask path: field names made
+// of letters, digits and underscores, not starting with a digit, joined by
+// dots, e.g. "address.zip_code". This allows building FieldMask updates
+// from flattened documents. Paths are joined by dots, so the separator set
+// by WithSeparator is overridden, whichever option comes first. A repeated
+// field may only end a path, so arrays must be kept whole with
+// WithFlattenArrays(false), as the values of repeated fields, rather than
+// indexed. Like WithValidateKeys, keys are checked after WithKeyFunc and
+// WithMaxKeyLength are applied.
+func WithFieldMask(mask bool) Option {
+	return func(o *options) {
+		o.fieldMask = mask
+	}
+}
+
+// validateFieldPath returns an error if the key is not a FieldMask path.
+func validateFieldPath(key string) error {
+	if key == "" {
+		return fmt.Errorf("flatjson: empty key is not a field mask path")
+	}
+
+	for _, name := range strings.Split(key, pathd) {
+		// Indices are bracketed or numeric segments, depending on the
+		// array notation.
+		if _, ok := parseIndex(name); ok || strings.Contains(name, "[") {
+			return fmt.Errorf("flatjson: key %q indexes an array, which a field mask path cannot; keep arrays whole with WithFlattenArrays(false)", key)
+		}
+
+		// Field names have the same form as jq identifiers.
+		if !isIdentifier(name) {
+			return fmt.Errorf("flatjson: key %q is not a field mask path", key)
+		}
+	}
+
+	return nil
+}
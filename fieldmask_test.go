@@ -0,0 +1,47 @@
+package flatjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldMask(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Options []Option
+		Keys    []string
+		Error   string
+	}{
+		{`{"user": {"display_name": "Bob", "zip2": 1}, "_x": true}`, nil, []string{"_x", "user.display_name", "user.zip2"}, ""},
+		{`{"user": {"tags": ["a", "b"]}}`, []Option{WithFlattenArrays(false)}, []string{"user.tags"}, ""},
+		{`{"a": {"b": 1}}`, []Option{WithSeparator("/"), WithFieldMask(true)}, []string{"a.b"}, ""},
+		{`{"a": {"b": 1}}`, []Option{WithSeparator("/")}, []string{"a.b"}, ""},
+		{`{"user": {"tags": ["a"]}}`, nil, nil, `key "user.tags[0]" indexes an array`},
+		{`{"user": {"tags": ["a"]}}`, []Option{WithArrayNotation(PlainNotation)}, nil, `key "user.tags.0" indexes an array`},
+		{`{"user": {"first name": "Bob"}}`, nil, nil, `key "user.first name" is not a field mask path`},
+		{`{"a.b": 1}`, nil, nil, `key "a\\.b" is not a field mask path`},
+		{`{"2fa": true}`, nil, nil, `key "2fa" is not a field mask path`},
+		{`"scalar"`, nil, nil, `empty key`},
+	}
+
+	for _, test := range tests {
+		keys, err := Keys(strings.NewReader(test.Input), append([]Option{WithFieldMask(true)}, test.Options...)...)
+
+		if test.Error != "" {
+			if err == nil || !strings.Contains(err.Error(), test.Error) {
+				t.Errorf("%s: expected error %q, got %v", test.Input, test.Error, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.Input, err)
+		}
+
+		if !reflect.DeepEqual(keys, test.Keys) {
+			t.Errorf("%s: expected %v, got %v", test.Input, test.Keys, keys)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package flatjson
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ConvertMapFile re-encodes the JSON file at path into a flat map written
+// to w. Files with a .gz extension are decompressed.
+func ConvertMapFile(path string, w io.Writer, opts ...Option) error {
+	return ConvertFile(path, func(r io.Reader) error {
+		return NewEncoder(w, opts...).ConvertMap(r)
+	})
+}
+
+// ConvertArrayFile re-encodes the JSON file at path into a flat array
+// written to w. Files with a .gz extension are decompressed.
+func ConvertArrayFile(path string, w io.Writer, opts ...Option) error {
+	return ConvertFile(path, func(r io.Reader) error {
+		return NewEncoder(w, opts...).ConvertArray(r)
+	})
+}
+
+// ConvertFile opens the file at path, calls convert with it and closes it.
+// Files with a .gz extension are decompressed.
+func ConvertFile(path string, convert func(io.Reader) error) error {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return fmt.Errorf("flatjson: %w", err)
+	}
+
+	defer f.Close()
+
+	if IsGzipPath(path) {
+		return ConvertGzip(f, convert)
+	}
+
+	return convert(f)
+}
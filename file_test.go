@@ -0,0 +1,84 @@
+package flatjson
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.json")
+
+	if err := os.WriteFile(path, []byte(`{"a": {"b": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := ConvertMapFile(path, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(buf.String()); s != `{"a.b":1}` {
+		t.Errorf("unexpected map output %s", s)
+	}
+
+	buf.Reset()
+
+	if err := ConvertArrayFile(path, buf, WithSeparator("/")); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(buf.String()); s != `[["a/b",1]]` {
+		t.Errorf("unexpected array output %s", s)
+	}
+
+	err := ConvertMapFile(filepath.Join(t.TempDir(), "missing.json"), buf)
+
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestConvertGzip(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(buf)
+
+	zw.Write([]byte(`{"a": {"b": 1}}`))
+	zw.Close()
+
+	path := filepath.Join(t.TempDir(), "doc.json.gz")
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	enc := NewEncoder(out)
+
+	if err := enc.ConvertArrayGzip(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(out.String()); s != `[["a.b",1]]` {
+		t.Errorf("unexpected array output %s", s)
+	}
+
+	out.Reset()
+
+	if err := ConvertMapFile(path, out); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(out.String()); s != `{"a.b":1}` {
+		t.Errorf("unexpected map output %s", s)
+	}
+
+	if err := enc.ConvertMapGzip(strings.NewReader(`{"a": 1}`)); err == nil {
+		t.Error("expected error for uncompressed input")
+	}
+}
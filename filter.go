@@ -0,0 +1,105 @@
+package flatjson
+
+import "strings"
+
+// keyFilter matches flattened keys against a glob pattern.
+type keyFilter struct {
+	segs []string
+	o    *options
+}
+
+func newKeyFilter(pattern string, o *options) *keyFilter {
+	return &keyFilter{
+		segs: splitSegments(pattern, o),
+		o:    o,
+	}
+}
+
+// match returns true if the key matches the pattern.
+func (f *keyFilter) match(key string) bool {
+	return matchSegments(f.segs, splitSegments(key, f.o))
+}
+
+// splitSegments splits a key on separators that are not escaped.
+func splitSegments(key string, o *options) []string {
+	if !o.escape {
+		return strings.Split(key, o.sep)
+	}
+
+	var (
+		segs  []string
+		start int
+	)
+
+	for i := 0; i < len(key); {
+		if key[i] == '\\' {
+			i += 2
+			continue
+		}
+
+		if strings.HasPrefix(key[i:], o.sep) {
+			segs = append(segs, key[start:i])
+			i += len(o.sep)
+			start = i
+			continue
+		}
+
+		i++
+	}
+
+	return append(segs, key[start:])
+}
+
+// matchSegments matches key segments against pattern segments.
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		// Try consuming any number of segments.
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(segs) == 0 || !matchGlob(pat[0], segs[0]) {
+			return false
+		}
+
+		pat = pat[1:]
+		segs = segs[1:]
+	}
+
+	return len(segs) == 0
+}
+
+// matchGlob matches a single segment where * matches any run of characters.
+func matchGlob(pat, s string) bool {
+	parts := strings.Split(pat, "*")
+
+	if len(parts) == 1 {
+		return pat == s
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+
+		if i < 0 {
+			return false
+		}
+
+		s = s[i+len(part):]
+	}
+
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
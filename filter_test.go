@@ -0,0 +1,60 @@
+package flatjson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyFilter(t *testing.T) {
+	input := `{
+		"name": "Bob Smith",
+		"address": {
+			"street": "123 Main Street",
+			"zipcode": 13943,
+			"geo": {"zipcode": 1},
+			"hobbies": ["tennis", "coding"]
+		}
+	}`
+
+	tests := []struct {
+		Pattern  string
+		Expected []string
+	}{
+		{"address.*", []string{"address.street", "address.zipcode", "address.hobbies[0]", "address.hobbies[1]"}},
+		{"**.zipcode", []string{"address.zipcode", "address.geo.zipcode"}},
+		{"address.**", []string{"address.street", "address.zipcode", "address.geo.zipcode", "address.hobbies[0]", "address.hobbies[1]"}},
+		{"**.hobbies*", []string{"address.hobbies[0]", "address.hobbies[1]"}},
+		{"*e", []string{"name"}},
+		{"nothing", nil},
+	}
+
+	for _, test := range tests {
+		pairs, err := Parse(strings.NewReader(input), WithKeyFilter(test.Pattern))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+
+		for _, p := range pairs {
+			keys = append(keys, p.Key)
+		}
+
+		if strings.Join(keys, ",") != strings.Join(test.Expected, ",") {
+			t.Errorf("%s: expected %v, got %v", test.Pattern, test.Expected, keys)
+		}
+	}
+}
+
+func TestKeyFilterEscaped(t *testing.T) {
+	pairs, err := Parse(strings.NewReader(`{"example.com": {"ttl": 1}, "a": {"ttl": 2}}`), WithKeyFilter("*.ttl"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 2 {
+		t.Errorf("expected 2 pairs, got %v", pairs)
+	}
+}
@@ -20,274 +20,1580 @@
 //        ["address.street", "123 Main Street"],
 //        ["address.city", "Boresville"],
 //        ["address.zipcode", 13943],
-//        ["hobbies.[0]", "tennis"],
-//        ["hobbies.[1]", "coding"],
-//        ["hobbies.[2]", "cooking"]
+//        ["hobbies[0]", "tennis"],
+//        ["hobbies[1]", "coding"],
+//        ["hobbies[2]", "cooking"]
 //    ]
 //
+// A top-level scalar such as 42 or "hello" is flattened to a single pair
+// with an empty key, or the prefix set with WithPrefix.
+//
+
+package flatjson
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Pair is a key-value Pair of JSON tokens.
+type Pair struct {
+	Key   string
+	Value interface{}
+
+	// Byte offset in the input where the value starts, set by WithOffsets.
+	Offset int64
+
+	// Segments of the key, set by WithPathSegments.
+	segs []interface{}
+}
+
+// String returns the pair as a JSON object member, e.g.
+// "address.city": "Boresville", so the key and value are unambiguous. Values
+// that cannot be encoded as JSON are formatted with %v.
+func (p *Pair) String() string {
+	k, _ := marshalNoEscape(p.Key)
+	v, err := marshalNoEscape(p.Value)
+
+	if err != nil {
+		return fmt.Sprintf("%s: %v", k, p.Value)
+	}
+
+	return k + ": " + v
+}
+
+// marshalNoEscape encodes v as JSON without escaping HTML characters.
+func marshalNoEscape(v interface{}) (string, error) {
+	var b strings.Builder
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+type tokArray [2]interface{}
+
+// arrayElem returns the pair as it is encoded in array output.
+func (o *options) arrayElem(p *Pair) interface{} {
+	p = o.outputPair(p)
+
+	switch {
+	case o.pairObjects:
+		return o.pairObject(p)
+	case o.pathSegments:
+		return tokArray{p.segs, p.Value}
+	}
+
+	return tokArray{p.Key, p.Value}
+}
+
+// arrayWriter writes array output one element at a time, formatted as
+// json.Encoder formats the whole array.
+type arrayWriter struct {
+	w       *bufio.Writer
+	indent  string
+	newline string
+	buf     bytes.Buffer
+	n       int
+}
+
+// write writes the next element.
+func (a *arrayWriter) write(v interface{}) error {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	a.buf.Reset()
+
+	if a.n == 0 {
+		a.buf.WriteByte('[')
+	} else {
+		a.buf.WriteByte(',')
+	}
+
+	if a.indent != "" {
+		a.buf.WriteString("\n" + a.indent)
+		json.Indent(&a.buf, b, a.indent, a.indent)
+	} else {
+		a.buf.Write(b)
+	}
+
+	a.n++
+
+	_, err = a.w.Write(a.buf.Bytes())
+
+	return err
+}
+
+// close ends the array and flushes the output.
+func (a *arrayWriter) close() error {
+	switch {
+	case a.n == 0:
+		a.w.WriteString("[]")
+	case a.indent != "":
+		a.w.WriteString("\n]")
+	default:
+		a.w.WriteString("]")
+	}
+
+	if a.newline == "" {
+		a.w.WriteByte('\n')
+	} else {
+		a.w.WriteString(a.newline)
+	}
+
+	return a.w.Flush()
+}
+
+// pairObject is a pair encoded as an object with the given field names.
+type pairObject struct {
+	pair       *Pair
+	key, value string
+
+	// Encode the key as its segments.
+	segs bool
+}
+
+func (o pairObject) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+
+	// Fields are written in order, which a map would not preserve.
+	buf.WriteByte('{')
+
+	var key interface{} = o.pair.Key
+
+	if o.segs {
+		key = o.pair.segs
+	}
+
+	for i, v := range []interface{}{o.key, key, o.value, o.pair.Value} {
+		switch i {
+		case 1, 3:
+			buf.WriteByte(':')
+		case 2:
+			buf.WriteByte(',')
+		}
+
+		if err := encodeTo(buf, enc, v); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// encodeTo encodes v to the buffer with the encoder, without the newline
+// the encoder adds.
+func encodeTo(buf *bytes.Buffer, enc *json.Encoder, v interface{}) error {
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+
+	buf.Truncate(buf.Len() - 1)
+
+	return nil
+}
+
+// orderedPairs encodes pairs as a JSON object with the keys in the order
+// of the pairs.
+type orderedPairs []*Pair
+
+func (m orderedPairs) MarshalJSON() ([]byte, error) {
+	// If keys collide, the last pair wins but is written where the key
+	// first appears.
+	last := make(map[string]*Pair, len(m))
+
+	for _, p := range m {
+		last[p.Key] = p
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+
+	buf.WriteByte('{')
+
+	for _, p := range m {
+		lp, ok := last[p.Key]
+
+		if !ok {
+			continue
+		}
+
+		delete(last, p.Key)
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		if err := encodeTo(buf, enc, lp.Key); err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte(':')
+
+		if err := encodeTo(buf, enc, lp.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Pairs is a set of key-value pairs.
+type mapPairs []*Pair
+
+// toMap returns the pairs as a map. If keys collide, the last pair wins.
+func (m mapPairs) toMap() map[string]interface{} {
+	aux := make(map[string]interface{}, len(m))
+
+	for _, p := range m {
+		aux[p.Key] = p.Value
+	}
+
+	return aux
+}
+
+func (m mapPairs) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.toMap())
+}
+
+// typedValue is a value encoded along with its JSON type.
+type typedValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// outputPair returns the pair with its value as it is encoded, converted
+// by WithStringValues and WithTypedValues. Other pairs are returned as is.
+func (o *options) outputPair(p *Pair) *Pair {
+	if !o.stringValues && !o.typedValues {
+		return p
+	}
+
+	c := *p
+
+	if o.stringValues {
+		c.Value = stringValue(p.Value)
+	}
+
+	// The type is that of the value before it was converted to a string.
+	if o.typedValues {
+		c.Value = typedValue{Type: p.Kind().String(), Value: c.Value}
+	}
+
+	return &c
+}
+
+// outputPairs returns the pairs with their values as they are encoded.
+func (o *options) outputPairs(pairs []*Pair) []*Pair {
+	if !o.stringValues && !o.typedValues {
+		return pairs
+	}
+
+	out := make([]*Pair, len(pairs))
+
+	for i, p := range pairs {
+		out[i] = o.outputPair(p)
+	}
+
+	return out
+}
+
+// stringValue returns a null, boolean or number as a string, using its
+// JSON encoding except for null, which is the empty string. Strings, maps
+// and arrays are returned as is.
+func stringValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	}
+
+	// Values not produced by decoding, such as those from a value function.
+	if k := kindOf(v); k == BoolKind || k == NumberKind {
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+
+	return v
+}
+
+// sortPairs sorts pairs lexicographically by key. Pairs with the same key
+// keep their relative order.
+func sortPairs(pairs []*Pair) {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+}
+
+// checkKeys returns an error if more than one pair has the same key.
+func checkKeys(pairs []*Pair) error {
+	seen := make(map[string]struct{}, len(pairs))
+
+	for _, p := range pairs {
+		if _, ok := seen[p.Key]; ok {
+			return fmt.Errorf("flatjson: duplicate key %q", p.Key)
+		}
+
+		seen[p.Key] = struct{}{}
+	}
+
+	return nil
+}
+
+// dedupeKeys returns the pairs with the second and later occurrences of a
+// key given the suffixes "#1", "#2" and so on. Suffixed keys that are
+// already in use are skipped.
+func dedupeKeys(pairs []*Pair) []*Pair {
+	keys := make(map[string]bool, len(pairs))
+
+	for _, p := range pairs {
+		keys[p.Key] = true
+	}
+
+	var (
+		out  = make([]*Pair, len(pairs))
+		seen = make(map[string]bool, len(pairs))
+		next = make(map[string]int)
+	)
+
+	for i, p := range pairs {
+		if !seen[p.Key] {
+			seen[p.Key] = true
+			out[i] = p
+			continue
+		}
+
+		n := next[p.Key]
+
+		var key string
+
+		for {
+			n++
+			key = p.Key + "#" + strconv.Itoa(n)
+
+			if !keys[key] && !seen[key] {
+				break
+			}
+		}
+
+		next[p.Key] = n
+		seen[key] = true
+
+		c := *p
+		c.Key = key
+		out[i] = &c
+	}
+
+	return out
+}
+
+// JSON delimiters.
+var (
+	lbrace  = json.Delim('{')
+	rbrace  = json.Delim('}')
+	lsquare = json.Delim('[')
+	rsquare = json.Delim(']')
+
+	pathd = "."
+)
+
+// skipRest reads past the remainder of a map or array without decoding it.
+func skipRest(dec tokenReader) error {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case lbrace, lsquare:
+			depth++
+		case rbrace, rsquare:
+			depth--
+		}
+	}
+
+	return nil
+}
+
+// closing returns the delimiter that closes the one opening a map or array.
+func closing(delim json.Delim) json.Delim {
+	if delim == lbrace {
+		return rbrace
+	}
+
+	return rsquare
+}
+
+// decodeRest decodes the remainder of the map or array opened by delim
+// into a value.
+func decodeRest(dec tokenReader, delim json.Delim) (interface{}, error) {
+	var (
+		m map[string]interface{}
+		a []interface{}
+	)
+
+	if delim == lbrace {
+		m = make(map[string]interface{})
+	} else {
+		a = make([]interface{}, 0)
+	}
+
+	for dec.More() {
+		var key string
+
+		if m != nil {
+			tok, err := dec.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			k, ok := tok.(string)
+
+			if !ok {
+				return nil, fmt.Errorf("flatjson: expected a map key, got %v", tok)
+			}
+
+			key = k
+		}
+
+		tok, err := dec.Token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if tok == rbrace || tok == rsquare {
+			return nil, ErrUnbalanced
+		}
+
+		if d, ok := tok.(json.Delim); ok {
+			if tok, err = decodeRest(dec, d); err != nil {
+				return nil, err
+			}
+		}
+
+		if m != nil {
+			m[key] = tok
+		} else {
+			a = append(a, tok)
+		}
+	}
+
+	// Consume the closing delimiter.
+	tok, err := dec.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tok != closing(delim) {
+		return nil, ErrUnbalanced
+	}
+
+	if m != nil {
+		return m, nil
+	}
+
+	return a, nil
+}
+
+// parseJSON decodes a JSON-encoded value into a set of pairs.
+func parseJSON(ctx context.Context, r io.Reader, o *options) ([]*Pair, error) {
+	var pairs []*Pair
+
+	err := parseFunc(ctx, r, o, func(p *Pair) error {
+		pairs = append(pairs, p)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// Pool of buffers used to encode values that are not converted directly.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Buffers that grow larger than this are not returned to the pool so a
+// single large value does not stay in memory.
+const maxPooledBuffer = 64 << 10
+
+// parseValueOf flattens a value already in memory. The value is converted
+// to the tokens its JSON encoding would produce, so the result matches
+// parsing the encoded value without the cost of encoding it.
+func parseValueOf(v interface{}, o *options) ([]*Pair, error) {
+	toks, err := appendTokens(nil, v, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []*Pair
+
+	err = parseValue(newParser(context.Background(), &tokenSlice{toks: toks}, o), func(p *Pair) error {
+		pairs = append(pairs, p)
+		return nil
+	})
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// UTF-8 byte order mark, which some editors write at the start of a file.
+const bom = "\xef\xbb\xbf"
+
+// skipBOM returns a reader that skips a byte order mark at the start of r.
+func skipBOM(r io.Reader) *bufio.Reader {
+	br := bufio.NewReader(r)
+
+	if b, _ := br.Peek(len(bom)); string(b) == bom {
+		br.Discard(len(bom))
+	}
+
+	return br
+}
+
+// limitReader returns an error once more than n bytes have been read.
+type limitReader struct {
+	r io.Reader
+
+	// Number of bytes that may still be read.
+	n int64
+
+	// The original limit, for the error.
+	max int64
+
+	// Set once the limit is exceeded and returned by every later read.
+	err error
+}
+
+func (l *limitReader) Read(b []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	// Read at most one byte past the limit to detect it was exceeded.
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+
+	if l.n < 0 {
+		l.err = fmt.Errorf("%w: more than %d bytes of input", ErrLimitExceeded, l.max)
+		return n + int(l.n), l.err
+	}
+
+	return n, err
+}
+
+// newJSONDecoder returns a JSON decoder for r configured for flattening
+// with the options. If rec is not nil, it records the input read by the
+// decoder.
+func newJSONDecoder(r io.Reader, o *options, rec *recorder) *json.Decoder {
+	if o.maxInputBytes > 0 {
+		r = &limitReader{r: r, n: o.maxInputBytes, max: o.maxInputBytes}
+	}
+
+	br := skipBOM(r)
+
+	if o.allowComments {
+		r = &commentReader{r: br}
+	} else {
+		r = br
+	}
+
+	if rec != nil {
+		rec.r = r
+		r = rec
+	}
+
+	dec := json.NewDecoder(r)
+
+	// Decode numbers as json.Number to preserve their precision.
+	dec.UseNumber()
+
+	return dec
+}
+
+// parseFunc decodes a JSON-encoded value and calls fn with each pair
+// as it is decoded.
+func parseFunc(ctx context.Context, r io.Reader, o *options, fn func(*Pair) error) error {
+	p := newReaderParser(ctx, r, o)
+
+	for {
+		err := parseValue(p, fn)
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseValue decodes the next top-level value with the parser and calls
+// fn with each pair. It returns io.EOF if there are no more values.
+func parseValue(p *parser, fn func(*Pair) error) error {
+	for {
+		pair, err := p.next()
+
+		if err == io.EOF {
+			if !p.started {
+				return io.EOF
+			}
+
+			p.reset()
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = fn(pair); err != nil {
+			return err
+		}
+	}
+}
+
+// Number of tokens read between checks for context cancellation.
+const ctxCheckInterval = 256
+
+// frame is an open map or array.
+type frame struct {
+	// Denotes the frame is an array rather than a map.
+	array bool
+
+	// Denotes no keys or elements have been read.
+	empty bool
+
+	// Denotes the next token will be a map key.
+	onkey bool
+
+	// Index of the next array element.
+	idx int
+
+	// Current key of the map.
+	key string
+
+	// Input offset of the opening delimiter.
+	off int64
+
+	// Denotes elements of the array were skipped.
+	truncated bool
+
+	// Denotes the array has a single element, which has no index.
+	single bool
+}
+
+// parser flattens top-level values from a stream of JSON tokens.
+type parser struct {
+	ctx context.Context
+	dec tokenReader
+	o   *options
+
+	// Number of tokens read, used to periodically check the context.
+	ntok int
+
+	// Open maps and arrays, innermost last.
+	stack []frame
+
+	// Key path parallel to the stack. Each segment is the current key
+	// of the map or the formatted index of the array at that level.
+	path []string
+
+	// Key of the path built incrementally, with the end of each segment
+	// in it, so only the last segment is rewritten as it changes.
+	keybuf []byte
+	ends   []int
+
+	// Denotes a token of the current value has been read.
+	started bool
+
+	// Denotes a previous top-level value has been read.
+	trailing bool
+
+	// Number of pairs returned, used to enforce WithMaxPairs.
+	npairs int
+
+	// Deepest nesting of maps and arrays seen.
+	depth int
+
+	// Denotes an empty map or array was collapsed.
+	collapsed bool
+
+	// Type of the first top-level value.
+	root Kind
+
+	// Records the input to locate tokens, set by WithOffsets, and the
+	// offset of the current token.
+	rec *recorder
+	off int64
+
+	// Tokens read ahead to find single element arrays, and the error that
+	// stopped reading ahead, set by WithUnwrapSingletonArrays.
+	ahead    []aheadToken
+	aheadErr error
+}
+
+func newParser(ctx context.Context, dec tokenReader, o *options) *parser {
+	return &parser{
+		ctx: ctx,
+		dec: dec,
+		o:   o,
+
+		// Pre-allocate 10 levels deep. Deeper values grow both with
+		// append, so there is no limit on nesting.
+		stack:  make([]frame, 0, 10),
+		path:   make([]string, 0, 10),
+		keybuf: make([]byte, 0, 64),
+		ends:   make([]int, 0, 10),
+	}
+}
+
+// newReaderParser returns a parser for the JSON input read from r.
+func newReaderParser(ctx context.Context, r io.Reader, o *options) *parser {
+	var rec *recorder
+
+	// Tokens replayed with duplicate keys grouped have no offsets.
+	if o.offsets && !o.duplicateKeys {
+		rec = &recorder{}
+	}
+
+	p := newParser(ctx, o.tokenReader(newJSONDecoder(r, o, rec)), o)
+	p.rec = rec
+
+	return p
+}
+
+// reset prepares the parser for the next top-level value.
+func (p *parser) reset() {
+	p.stack = p.stack[:0]
+	p.path = p.path[:0]
+	p.ends = p.ends[:0]
+	p.started = false
+	p.trailing = true
+}
+
+// key serializes the current path into a key.
+func (p *parser) key() string {
+	n := 0
+
+	if len(p.ends) > 0 {
+		n = p.ends[len(p.ends)-1]
+	}
+
+	return p.o.prefixKey(string(p.keybuf[:n]))
+}
+
+// setSegment sets the last segment of the path and rebuilds the key from
+// the end of the segment before it.
+func (p *parser) setSegment(seg string) {
+	i := len(p.path) - 1
+	p.path[i] = seg
+
+	n := 0
+
+	if i > 0 {
+		n = p.ends[i-1]
+	}
+
+	p.keybuf = p.keybuf[:n]
+
+	// Bracketed indices are appended directly to their array's key, as is
+	// the element of a single element array, and query syntax segments
+	// carry their own separators.
+	if i > 0 && p.o.syntax == defaultSyntax && !(p.stack[i].array && (p.o.notation == BracketNotation || p.stack[i].single)) && p.follows(i) {
+		p.keybuf = append(p.keybuf, p.o.sep...)
+	}
+
+	p.keybuf = append(p.keybuf, seg...)
+	p.ends[i] = len(p.keybuf)
+}
+
+// follows returns true if a segment precedes the one at i, which is only
+// false if every array before it is a single element array.
+func (p *parser) follows(i int) bool {
+	if !p.o.unwrapSingle {
+		return true
+	}
+
+	for _, f := range p.stack[:i] {
+		if !f.single {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pair returns the pair for the value at the current path, or nil if
+// the pair is filtered out or skipped.
+func (p *parser) pair(value interface{}) *Pair {
+	key := p.key()
+
+	if p.o.filter != nil && !p.o.filter.match(key) {
+		return nil
+	}
+
+	if p.o.keyFunc != nil {
+		key = p.o.keyFunc(key)
+	}
+
+	if p.o.depthPrefix {
+		key = strconv.Itoa(len(p.path)) + ":" + key
+	}
+
+	if p.o.maxKeyLen > 0 && utf8.RuneCountInString(key) > p.o.maxKeyLen {
+		key = p.o.shortenKey(key, p.o.maxKeyLen)
+	}
+
+	if s, ok := value.(string); ok && p.o.trimSpace {
+		value = strings.TrimSpace(s)
+	}
+
+	if n, ok := value.(json.Number); ok && p.o.plainNumbers {
+		value = plainNumber(n)
+	}
+
+	if p.o.valueFunc != nil {
+		value = p.o.valueFunc(key, value)
+	}
+
+	if value == nil && p.o.skipNulls {
+		return nil
+	}
+
+	pair := &Pair{
+		Key:   key,
+		Value: value,
+	}
+
+	if p.rec != nil {
+		pair.Offset = p.off
+	}
+
+	if p.o.pathSegments {
+		pair.segs = p.segments()
+	}
+
+	if s := p.o.stats; s != nil {
+		s.pairs++
+		s.kinds[kindOf(value)]++
+	}
+
+	return pair
+}
+
+// segments returns the current path as literal map keys and integer array
+// indices, starting with the prefix if there is one.
+func (p *parser) segments() []interface{} {
+	segs := make([]interface{}, 0, len(p.path)+1)
+
+	if p.o.prefix != "" {
+		segs = append(segs, p.o.prefix)
+	}
+
+	for _, f := range p.stack[:len(p.path)] {
+		if f.single {
+			continue
+		}
+
+		if f.array {
+			segs = append(segs, f.idx-1)
+		} else {
+			segs = append(segs, f.key)
+		}
+	}
+
+	return segs
+}
+
+// top returns the innermost open map or array, or nil at the top level.
+func (p *parser) top() *frame {
+	if len(p.stack) == 0 {
+		return nil
+	}
+
+	return &p.stack[len(p.stack)-1]
+}
+
+// next returns the next pair of the current top-level value. It returns
+// io.EOF once the value is complete or if there is no more input.
+func (p *parser) next() (*Pair, error) {
+	pair, err := p.scan()
+
+	if pair != nil && p.o.maxPairs > 0 {
+		if p.npairs++; p.npairs > p.o.maxPairs {
+			return nil, fmt.Errorf("%w: more than %d pairs", ErrLimitExceeded, p.o.maxPairs)
+		}
+	}
+
+	if pair != nil && p.o.validateKeys {
+		if err := validateKey(pair.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	if pair != nil && p.o.fieldMask {
+		if err := validateFieldPath(pair.Key); err != nil {
+			return nil, err
+		}
+	}
+
+	return pair, err
+}
+
+// validateKey returns an error if the key contains a NUL or other C0
+// control character.
+func validateKey(key string) error {
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 {
+			return fmt.Errorf("flatjson: key %q contains control character %U", key, rune(key[i]))
+		}
+	}
+
+	return nil
+}
+
+// scan reads tokens up to the next pair of the current top-level value.
+func (p *parser) scan() (*Pair, error) {
+	var (
+		// Current token.
+		tok json.Token
+
+		err error
+	)
+
+	for {
+		// The top-level value is complete.
+		if p.started && len(p.stack) == 0 {
+			return nil, io.EOF
+		}
+
+		// Check for cancellation periodically since it is not free.
+		if p.ntok%ctxCheckInterval == 0 {
+			if err = p.ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		p.ntok++
+
+		var off int64
+
+		tok, off, err = p.token()
+
+		// No more values.
+		if err == io.EOF && len(p.stack) == 0 {
+			return nil, err
+		}
+
+		// Input after a complete value that does not start another.
+		if err != nil && !p.started && p.trailing && err != io.EOF && !errors.Is(err, ErrLimitExceeded) {
+			return nil, newTrailingDataError(p.dec, err)
+		}
+
+		if err != nil {
+			return nil, newParseError(p.dec, p.key(), err)
+		}
+
+		p.started = true
+		p.off = off
+
+		top := p.top()
+
+		// End of a map or array, which must close the innermost one open
+		// and not follow a map key.
+		if tok == rbrace || tok == rsquare {
+			if top == nil || top.array != (tok == rsquare) || !top.array && !top.onkey {
+				return nil, newParseError(p.dec, p.key(), ErrUnbalanced)
+			}
+
+			// A truncated array is marked by a pair keyed as if the
+			// array were a map.
+			var marker *Pair
+
+			if top.truncated {
+				top.array = false
+				top.key = truncatedKey
+				p.setSegment(p.o.segment(truncatedKey))
+				marker = p.pair(true)
+			}
+
+			closed := *top
+			empty := closed.empty
+
+			p.stack = p.stack[:len(p.stack)-1]
+			p.path = p.path[:len(p.stack)]
+			p.ends = p.ends[:len(p.stack)]
+
+			if top = p.top(); top != nil {
+				// The next token in the enclosing map is a key.
+				top.onkey = !top.array
+
+				// An empty map or array is a value of its own, unless
+				// it was already emitted as a container.
+				if empty && !p.o.containers {
+					p.collapsed = p.collapsed || !p.o.keepEmpty
+					p.off = closed.off
+
+					if pair := p.pair(p.o.empty(tok.(json.Delim))); pair != nil {
+						return pair, nil
+					}
+				}
+			}
+
+			if marker != nil {
+				return marker, nil
+			}
+
+			continue
+		}
+
+		// Add the map key to the path.
+		if top != nil && top.onkey {
+			key, ok := tok.(string)
+
+			if !ok {
+				return nil, newParseError(p.dec, p.key(), fmt.Errorf("flatjson: expected a map key, got %v", tok))
+			}
+
+			top.key = key
+			p.setSegment(p.o.segment(top.key))
+			top.onkey = false
+			top.empty = false
+			continue
+		}
+
+		// Top-level values other than maps are rejected if required.
+		if top == nil {
+			kind := tokenKind(tok)
+
+			if !p.trailing {
+				p.root = kind
+			}
+
+			if p.o.objectRoot && kind != ObjectKind {
+				return nil, fmt.Errorf("flatjson: expected object root, got %s", kind)
+			}
+		}
+
+		// The token starts a value, so advance the array index.
+		if top != nil {
+			top.empty = false
 
-package flatjson
+			// Elements past the maximum are skipped.
+			if top.array && p.o.maxArrayLen > 0 && top.idx >= p.o.maxArrayLen {
+				top.truncated = true
 
-import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
-	"strings"
-)
+				if tok == lbrace || tok == lsquare {
+					if err = skipRest(p.reader()); err != nil {
+						return nil, newParseError(p.dec, p.key(), err)
+					}
+				}
 
-// Pair is a key-value Pair of JSON tokens.
-type Pair struct {
-	Key   string
-	Value interface{}
-}
+				continue
+			}
 
-func (p *Pair) String() string {
-	return fmt.Sprintf("[%s: %v]", p.Key, p.Value)
-}
+			if top.array && !top.single {
+				p.setSegment(p.o.index(top.idx))
+				top.idx++
+			}
+		}
 
-type tokArray [2]interface{}
+		// Past the maximum depth the map or array is kept whole and
+		// handled like any other value, as are nested arrays if they
+		// are not being flattened. Arrays kept as arrays have their
+		// elements flattened.
+		if whole := p.o.maxDepth > 0 && len(p.stack) >= p.o.maxDepth; whole && (tok == lbrace || tok == lsquare) ||
+			(!p.o.flattenArrays || p.o.arraysAsArrays) && len(p.stack) > 0 && tok == lsquare {
+			tok, err = decodeRest(p.reader(), tok.(json.Delim))
+
+			if a, ok := tok.([]interface{}); ok && err == nil && p.o.arraysAsArrays && !whole {
+				tok, err = p.o.flattenElements(a)
+			}
+
+			if err != nil {
+				return nil, newParseError(p.dec, p.key(), err)
+			}
+		}
+
+		// Start of a map or array.
+		if tok == lbrace || tok == lsquare {
+			var pair *Pair
+
+			// Nested containers are emitted before their contents.
+			if p.o.containers && top != nil {
+				pair = p.pair(tokenKind(tok).String())
+			}
+
+			p.stack = append(p.stack, frame{
+				array: tok == lsquare,
+				empty: true,
+				onkey: tok == lbrace,
+				off:   p.off,
+			})
+
+			if tok == lsquare && p.o.unwrapSingle {
+				p.stack[len(p.stack)-1].single = p.readAhead()
+			}
 
-type arrayPairs []*Pair
+			p.path = append(p.path, "")
+			p.ends = append(p.ends, 0)
+			p.setSegment("")
 
-func (a arrayPairs) MarshalJSON() ([]byte, error) {
-	aux := make([]tokArray, len(a))
+			if len(p.stack) > p.depth {
+				p.depth = len(p.stack)
+			}
+
+			if s := p.o.stats; s != nil {
+				if tok == lsquare {
+					s.arrays++
+				} else {
+					s.objects++
+				}
+
+				if p.depth > s.depth {
+					s.depth = p.depth
+				}
+			}
+
+			if pair != nil {
+				return pair, nil
+			}
+
+			continue
+		}
+
+		// The next token in the enclosing map is a key.
+		if top != nil {
+			top.onkey = !top.array
+		}
 
-	for i, p := range a {
-		aux[i] = tokArray{json.Token(p.Key), p.Value}
+		// A top-level scalar has no path, so its key is empty.
+		if pair := p.pair(tok); pair != nil {
+			return pair, nil
+		}
 	}
+}
 
-	return json.Marshal(aux)
+// Encoder encodes a value into a flat JSON map or array. It is created by
+// NewEncoder; a zero Encoder must be Reset before use.
+type Encoder struct {
+	w    io.Writer
+	opts options
 }
 
-// Pairs is a set of key-value pairs.
-type mapPairs []*Pair
+// EncodeArray encodes a value as a flat JSON array.
+func (f *Encoder) EncodeArray(v interface{}) error {
+	pairs, err := parseValueOf(v, &f.opts)
 
-func (m mapPairs) MarshalJSON() ([]byte, error) {
-	aux := make(map[string]json.Token, len(m))
+	if err != nil {
+		return err
+	}
 
-	for _, p := range m {
-		aux[p.Key] = p.Value
+	return f.writeArray(pairs)
+}
+
+// EncodeMap encodes a value as a flat JSON map.
+func (f *Encoder) EncodeMap(v interface{}) error {
+	pairs, err := parseValueOf(v, &f.opts)
+
+	if err != nil {
+		return err
 	}
 
-	return json.Marshal(aux)
+	return f.writeMap(pairs)
 }
 
-// JSON delimiters.
-var (
-	lbrace  = json.Delim('{')
-	rbrace  = json.Delim('}')
-	lsquare = json.Delim('[')
-	rsquare = json.Delim(']')
+// ConvertArray re-encodes a JSON value into a flat array.
+func (f *Encoder) ConvertArray(r io.Reader) error {
+	return f.ConvertArrayContext(context.Background(), r)
+}
 
-	pathd = "."
-)
+// ConvertMap re-encodes a JSON value into a flat map.
+func (f *Encoder) ConvertMap(r io.Reader) error {
+	return f.ConvertMapContext(context.Background(), r)
+}
 
-// parseJSON decodes a JSON-encoded value into a set of pairs.
-func parseJSON(r io.Reader) ([]*Pair, error) {
-	var (
-		// Current token.
-		tok json.Token
+// ConvertArrayContext re-encodes a JSON value into a flat array. Conversion
+// is aborted with the context's error if it is done before completing.
+//
+// Unless WithSortKeys, WithRoot or WithMetaHeader is set, pairs are written
+// as they are decoded rather than held in memory, so an error in a large
+// input may leave an incomplete array in the output.
+func (f *Encoder) ConvertArrayContext(ctx context.Context, r io.Reader) error {
+	// Pairs are written as they are decoded unless they must be sorted or
+	// the output wrapped.
+	if !f.opts.sortKeys && f.opts.root == "" && f.opts.metaKey == "" {
+		a := &arrayWriter{
+			w:       bufio.NewWriter(f.w),
+			indent:  f.opts.indent,
+			newline: f.opts.newline,
+		}
 
-		// Current converted key-value Pair.
-		key   string
-		value interface{}
+		err := parseFunc(ctx, r, &f.opts, func(p *Pair) error {
+			return a.write(f.opts.arrayElem(p))
+		})
 
-		// Set of key-value pairs.
-		pairs []*Pair
+		if err != nil {
+			return err
+		}
 
-		err error
+		return a.close()
+	}
+
+	pairs, err := parseJSON(ctx, r, &f.opts)
+
+	if err != nil {
+		return err
+	}
+
+	return f.writeArray(pairs)
+}
 
-		// Denotes the decoder just entered an map or array.
-		inmap bool
-		inarr bool
+// ConvertMapContext re-encodes a JSON value into a flat map. Conversion
+// is aborted with the context's error if it is done before completing.
+func (f *Encoder) ConvertMapContext(ctx context.Context, r io.Reader) error {
+	pairs, err := parseJSON(ctx, r, &f.opts)
 
-		// Denotes whether the current map or array is empty.
-		empty bool
+	if err != nil {
+		return err
+	}
 
-		// Denotes the next token will be an map key.
-		onkey bool
+	return f.writeMap(pairs)
+}
 
-		// The current index in the array.
-		arridx = []byte("[0]")
-		arrkey string
+// ConvertMapLines re-encodes a stream of JSON values, such as newline-delimited
+// JSON, into flat maps written one per line.
+func (f *Encoder) ConvertMapLines(r io.Reader) error {
+	return f.convertLines(r, f.writeMap)
+}
 
-		// Pre-allocate 10 levels deep
-		path = make([]string, 10)
-		dest []string
+// ConvertArrayLines re-encodes a stream of JSON values, such as newline-delimited
+// JSON, into flat arrays written one per line.
+func (f *Encoder) ConvertArrayLines(r io.Reader) error {
+	return f.convertLines(r, f.writeArray)
+}
 
-		pos = -1
+// ConvertMapSequence re-encodes a stream of JSON values, such as objects
+// concatenated in a log like {"a":1}{"b":2}, into a JSON array with the
+// flat map of each value, e.g. [{"a":1},{"b":2}]. Each value is flattened
+// separately as by ConvertMapLines. Unless WithRoot or WithMetaHeader is
+// set, each map is written once its value is decoded rather than held in
+// memory, so an error may leave an incomplete array in the output.
+func (f *Encoder) ConvertMapSequence(r io.Reader) error {
+	var (
+		stream = f.opts.root == "" && f.opts.metaKey == ""
+		maps   = []interface{}{}
 	)
 
-	dec := json.NewDecoder(r)
-
-	for {
-		tok, err = dec.Token()
+	a := &arrayWriter{
+		w:       bufio.NewWriter(f.w),
+		indent:  f.opts.indent,
+		newline: f.opts.newline,
+	}
 
-		if err == io.EOF {
-			break
-		}
+	err := f.convertLines(r, func(pairs []*Pair) error {
+		v, err := f.opts.mapValue(pairs)
 
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// Evaluate the token to determine next key-value pair.
-		switch tok.(type) {
-		case json.Delim:
-			switch tok {
-			case lbrace:
-				empty = true
-				inmap = true
-				onkey = true
-				pos++
-
-				// Double the size
-				if pos == len(path) {
-					dest = make([]string, pos*2)
-					copy(dest, path)
-					path = dest
-				}
+		if stream {
+			return a.write(v)
+		}
 
-			case rbrace:
-				if empty && pos > 0 {
-					pairs = append(pairs, &Pair{
-						Key: strings.Join(path[:pos+1], pathd),
-					})
-				}
+		maps = append(maps, v)
 
-				inmap = false
-				// This is here because the map may be empty.
-				onkey = true
-				pos--
+		return nil
+	})
 
-			case lsquare:
-				empty = true
-				inarr = true
+	if err != nil {
+		return err
+	}
 
-				// Reset the array index.
-				arridx[1] = '0'
+	if !stream {
+		return f.jsonEncoder().Encode(f.opts.wrap(maps))
+	}
 
-				if pos > 0 {
-					arrkey = path[pos]
-				} else {
-					arrkey = ""
-				}
+	return a.close()
+}
 
-			case rsquare:
-				if empty && pos >= 0 {
-					pairs = append(pairs, &Pair{
-						Key: strings.Join(path[:pos+1], pathd),
-					})
-				}
+// ConvertPairsLines re-encodes a JSON value, or a stream of them, into
+// newline-delimited JSON with one {"key": ..., "value": ...} object per pair.
+// Pairs are written as they are decoded rather than buffered, so they are
+// neither sorted nor indented. The field names are set by WithPairObjects.
+func (f *Encoder) ConvertPairsLines(r io.Reader) error {
+	enc := json.NewEncoder(f.lineWriter())
 
-				inarr = false
-			}
+	return parseFunc(context.Background(), r, &f.opts, func(p *Pair) error {
+		p = f.opts.outputPair(p)
 
-		// Keys and values.
-		default:
-			empty = false
+		return enc.Encode(f.opts.pairObject(p))
+	})
+}
 
-			// The current token is the key of a map
-			if onkey {
-				// Add to key path and increment the position.
-				path[pos] = tok.(string)
-				onkey = false
+// convertLines flattens each value in the stream separately and writes the
+// results as newline-delimited JSON.
+func (f *Encoder) convertLines(r io.Reader, write func([]*Pair) error) error {
+	p := newReaderParser(context.Background(), r, &f.opts)
 
-				// Token is a map or array value.
-			} else {
-				value = tok
+	for {
+		var pairs []*Pair
 
-				if inarr {
-					// Only occurs when the top-level value is an array.
-					if pos < 0 {
-						pos = 0
-					}
+		err := parseValue(p, func(pair *Pair) error {
+			pairs = append(pairs, pair)
+			return nil
+		})
 
-					path[pos] = arrkey + string(arridx)
-					arridx[1]++
-				} else if inmap {
-					onkey = true
-				}
+		if err == io.EOF {
+			return nil
+		}
 
-				// Serialize path into key.
-				key = strings.Join(path[:pos+1], pathd)
+		if err != nil {
+			return err
+		}
 
-				pairs = append(pairs, &Pair{
-					Key:   key,
-					Value: value,
-				})
-			}
+		if err := write(pairs); err != nil {
+			return err
 		}
 	}
+}
+
+// lineWriter returns the output, replacing the newline json.Encoder writes
+// after each value with the line terminator if one is set.
+func (f *Encoder) lineWriter() io.Writer {
+	if f.opts.newline == "" {
+		return f.w
+	}
 
-	return pairs, nil
+	return &lineWriter{w: f.w, newline: f.opts.newline}
 }
 
-// Encoder encodes a value into a flat JSON map or array.
-type Encoder struct {
-	w io.Writer
+// lineWriter replaces the newline ending each write with another line
+// terminator. json.Encoder writes each value, along with its newline, in a
+// single write.
+type lineWriter struct {
+	w       io.Writer
+	newline string
 }
 
-// EncodeArray encodes a value as a flat JSON array.
-func (f *Encoder) EncodeArray(v interface{}) error {
-	buf := bytes.NewBuffer(nil)
+func (l *lineWriter) Write(b []byte) (int, error) {
+	n := len(b)
 
-	if err := json.NewEncoder(buf).Encode(v); err != nil {
-		return err
+	if n == 0 || b[n-1] != '\n' {
+		return l.w.Write(b)
 	}
 
-	pairs, err := parseJSON(buf)
+	if _, err := l.w.Write(b[:n-1]); err != nil {
+		return 0, err
+	}
 
-	if err != nil {
-		return err
+	if _, err := io.WriteString(l.w, l.newline); err != nil {
+		return 0, err
 	}
 
-	return json.NewEncoder(f.w).Encode(arrayPairs(pairs))
+	return n, nil
 }
 
-// EncodeMap encodes a value as a flat JSON map.
-func (f *Encoder) EncodeMap(v interface{}) error {
-	buf := bytes.NewBuffer(nil)
+// jsonEncoder returns a JSON encoder for the output.
+func (f *Encoder) jsonEncoder() *json.Encoder {
+	enc := json.NewEncoder(f.lineWriter())
 
-	if err := json.NewEncoder(buf).Encode(v); err != nil {
-		return err
+	if f.opts.indent != "" {
+		enc.SetIndent("", f.opts.indent)
 	}
 
-	pairs, err := parseJSON(buf)
+	return enc
+}
 
-	if err != nil {
-		return err
+// wrap returns the output nested under the root key and preceded by the
+// meta header, if they are set.
+func (o *options) wrap(v interface{}) interface{} {
+	if o.root != "" {
+		v = map[string]interface{}{o.root: v}
 	}
 
-	return json.NewEncoder(f.w).Encode(mapPairs(pairs))
+	return o.withMeta(v)
 }
 
-// ConvertArray re-encodes a JSON value into a flat array.
-func (f *Encoder) ConvertArray(r io.Reader) error {
-	pairs, err := parseJSON(r)
+// writeArray writes the pairs as a flat JSON array.
+func (f *Encoder) writeArray(pairs []*Pair) error {
+	if f.opts.sortKeys {
+		sortPairs(pairs)
+	}
 
-	if err != nil {
-		return err
+	aux := make([]interface{}, len(pairs))
+
+	for i, p := range pairs {
+		aux[i] = f.opts.arrayElem(p)
 	}
 
-	return json.NewEncoder(f.w).Encode(arrayPairs(pairs))
+	return f.jsonEncoder().Encode(f.opts.wrap(aux))
 }
 
-// ConvertMap re-encodes a JSON value into a flat map.
-func (f *Encoder) ConvertMap(r io.Reader) error {
-	pairs, err := parseJSON(r)
+// writeMap writes the pairs as a flat JSON map.
+func (f *Encoder) writeMap(pairs []*Pair) error {
+	v, err := f.opts.mapValue(pairs)
 
 	if err != nil {
 		return err
 	}
 
-	return json.NewEncoder(f.w).Encode(mapPairs(pairs))
+	return f.jsonEncoder().Encode(f.opts.wrap(v))
+}
+
+// mapValue returns the pairs as a value encoded as a flat JSON map.
+func (o *options) mapValue(pairs []*Pair) (interface{}, error) {
+	pairs = o.outputPairs(pairs)
+
+	if o.dedupeKeys {
+		pairs = dedupeKeys(pairs)
+	}
+
+	if o.strictKeys {
+		if err := checkKeys(pairs); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.sortKeys {
+		sortPairs(pairs)
+	}
+
+	if o.preserveOrder {
+		return orderedPairs(pairs), nil
+	}
+
+	return mapPairs(pairs), nil
+}
+
+// Reset replaces the writer of the Encoder so it can be reused and clears
+// its Stats. The options are kept, except that a zero Encoder is given the
+// default options, as if created by NewEncoder.
+func (f *Encoder) Reset(w io.Writer) {
+	if f.opts.stats == nil {
+		*f = *NewEncoder(w)
+		return
+	}
+
+	f.w = w
+	f.opts.stats = &stats{}
+}
+
+// countWriter counts the bytes written to the underlying writer.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// count calls fn with the output counted and returns the number of bytes
+// written by it.
+func (f *Encoder) count(fn func() error) (int64, error) {
+	c := &countWriter{w: f.w}
+	f.w = c
+
+	defer func() {
+		f.w = c.w
+	}()
+
+	err := fn()
+
+	return c.n, err
+}
+
+// EncodeArrayN is like EncodeArray and returns the number of bytes written.
+func (f *Encoder) EncodeArrayN(v interface{}) (int64, error) {
+	return f.count(func() error {
+		return f.EncodeArray(v)
+	})
+}
+
+// EncodeMapN is like EncodeMap and returns the number of bytes written.
+func (f *Encoder) EncodeMapN(v interface{}) (int64, error) {
+	return f.count(func() error {
+		return f.EncodeMap(v)
+	})
+}
+
+// ConvertArrayN is like ConvertArray and returns the number of bytes written.
+func (f *Encoder) ConvertArrayN(r io.Reader) (int64, error) {
+	return f.count(func() error {
+		return f.ConvertArray(r)
+	})
+}
+
+// ConvertMapN is like ConvertMap and returns the number of bytes written.
+func (f *Encoder) ConvertMapN(r io.Reader) (int64, error) {
+	return f.count(func() error {
+		return f.ConvertMap(r)
+	})
 }
 
 // NewEncoder initializes a new Encoder for the writer.
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	o := newOptions(opts)
+	o.stats = &stats{}
+
+	return &Encoder{
+		w:    w,
+		opts: o,
+	}
 }
 
 // EncodeMap encodes a value into a flat JSON map.
@@ -338,7 +1644,135 @@ func ConvertArray(r io.Reader) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ConvertMapBytes re-encodes JSON held in memory into a flat map.
+func ConvertMapBytes(b []byte, opts ...Option) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, opts...)
+
+	if err := enc.ConvertMap(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ConvertArrayBytes re-encodes JSON held in memory into a flat array.
+func ConvertArrayBytes(b []byte, opts ...Option) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, opts...)
+
+	if err := enc.ConvertArray(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ParseFunc calls fn for each key-value pair as it is decoded, without
+// buffering the full set. If fn returns an error, decoding stops and the
+// error is returned.
+func ParseFunc(r io.Reader, fn func(*Pair) error, opts ...Option) error {
+	o := newOptions(opts)
+	return parseFunc(context.Background(), r, &o, fn)
+}
+
+// Flatten flattens a value into a map of keys to values. Numbers are
+// represented as json.Number. If two pairs resolve to the same key, the
+// last one wins, matching EncodeMap, unless WithStrictKeys is set.
+func Flatten(v interface{}, opts ...Option) (map[string]interface{}, error) {
+	o := newOptions(opts)
+
+	pairs, err := parseValueOf(v, &o)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if o.dedupeKeys {
+		pairs = dedupeKeys(pairs)
+	}
+
+	if o.strictKeys {
+		if err := checkKeys(pairs); err != nil {
+			return nil, err
+		}
+	}
+
+	return mapPairs(pairs).toMap(), nil
+}
+
 // Parse returns a slice of key-value pairs.
-func Parse(r io.Reader) ([]*Pair, error) {
-	return parseJSON(r)
+func Parse(r io.Reader, opts ...Option) ([]*Pair, error) {
+	o := newOptions(opts)
+	return parseJSON(context.Background(), r, &o)
+}
+
+// ParseBytes returns a slice of key-value pairs of JSON held in memory.
+func ParseBytes(b []byte, opts ...Option) ([]*Pair, error) {
+	return Parse(bytes.NewReader(b), opts...)
+}
+
+// FlattenRaw returns the key-value pairs of a raw JSON value stored under
+// the key of a map, with the key prepended as if the value were flattened
+// within the map, e.g. "address.city" for the key "address". This allows
+// flattening only some entries of a map[string]json.RawMessage. The key is
+// escaped like any other map key and follows the prefix, if one is set.
+func FlattenRaw(key string, raw json.RawMessage, opts ...Option) ([]*Pair, error) {
+	o := newOptions(opts)
+	o.prefix = o.prefixKey(o.segment(key))
+
+	return parseJSON(context.Background(), bytes.NewReader(raw), &o)
+}
+
+// ParseDecoder returns the key-value pairs of the next value read from an
+// open decoder, leaving the decoder positioned after it, so the value can
+// be part of a larger stream the caller is reading. For example, elements
+// of an array can be flattened one at a time by calling ParseDecoder while
+// dec.More returns true. It returns io.EOF if there are no more values.
+//
+// Numbers are decoded as the decoder is configured to, as float64 unless
+// UseNumber was called on it. Options that apply to reading the input,
+// such as WithAllowComments, WithMaxInputBytes and WithOffsets, are
+// ignored.
+func ParseDecoder(dec *json.Decoder, opts ...Option) ([]*Pair, error) {
+	o := newOptions(opts)
+
+	var pairs []*Pair
+
+	err := parseValue(newParser(context.Background(), o.tokenReader(dec), &o), func(p *Pair) error {
+		pairs = append(pairs, p)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// Keys returns the sorted, unique keys of the pairs a JSON value is
+// flattened to, which describe the shape of the document.
+func Keys(r io.Reader, opts ...Option) ([]string, error) {
+	o := newOptions(opts)
+	seen := make(map[string]struct{})
+
+	err := parseFunc(context.Background(), r, &o, func(p *Pair) error {
+		seen[p.Key] = struct{}{}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(seen))
+
+	for k := range seen {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
 }
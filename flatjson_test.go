@@ -1,9 +1,17 @@
 package flatjson
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 type jsonTest struct {
@@ -75,21 +83,2358 @@ func TestParse(t *testing.T) {
 	}
 }
 
+// Document used by benchmarks and tests of the reader.
+var benchDoc = `
+	{
+		"name": "Bob Smith",
+		"address": {
+			"street": "123 Main Street",
+			"city": "Boresville",
+			"zipcode": 13943
+		},
+		"hobbies": ["tennis", "coding", "cooking"]
+	}
+`
+
 func BenchmarkParse(b *testing.B) {
-	var json = `
+	for i := 0; i < b.N; i++ {
+		r := strings.NewReader(benchDoc)
+		Parse(r)
+	}
+}
+
+// deepDoc returns a document nested depth maps deep with a few values at
+// each level.
+func deepDoc(depth int) string {
+	var b strings.Builder
+
+	for i := 0; i < depth; i++ {
+		fmt.Fprintf(&b, `{"name%d": "level", "tags": [1, 2, 3], "child": `, i)
+	}
+
+	b.WriteString("null")
+	b.WriteString(strings.Repeat("}", depth))
+
+	return b.String()
+}
+
+func BenchmarkParseDeep(b *testing.B) {
+	doc := deepDoc(20)
+
+	for i := 0; i < b.N; i++ {
+		r := strings.NewReader(doc)
+		Parse(r)
+	}
+}
+
+func TestPartialReads(t *testing.T) {
+	expected, err := Parse(strings.NewReader(benchDoc))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers := map[string]func(io.Reader) io.Reader{
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data err": iotest.DataErrReader,
+	}
+
+	for name, wrap := range readers {
+		actual, err := Parse(wrap(strings.NewReader(benchDoc + benchDoc)))
+
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(append(expected, expected...), actual) {
+			t.Errorf("%s: expected %v, got %v", name, expected, actual)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	input := []byte(`{"a": {"b": [1]}}`)
+
+	pairs, err := ParseBytes(input, WithPrefix("p"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1 || pairs[0].Key != "p.a.b[0]" {
+		t.Errorf("expected p.a.b[0], got %v", pairs)
+	}
+
+	b, err := ConvertMapBytes(input, WithSeparator("/"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"a/b[0]":1}` {
+		t.Errorf("expected map, got %s", s)
+	}
+
+	b, err = ConvertArrayBytes(input)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `[["a.b[0]",1]]` {
+		t.Errorf("expected array, got %s", s)
+	}
+
+	if _, err := ConvertMapBytes([]byte(`{"a": `)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestFlattenRaw(t *testing.T) {
+	m := map[string]json.RawMessage{
+		"address": json.RawMessage(`{"city": "Boresville", "geo": [1.5, 2]}`),
+		"a.b":     json.RawMessage(`[true]`),
+		"n":       json.RawMessage(`null`),
+	}
+
+	tests := []struct {
+		Key      string
+		Options  []Option
+		Expected string
+	}{
+		{"address", nil, `[["address.city","Boresville"],["address.geo[0]",1.5],["address.geo[1]",2]]`},
+		{"address", []Option{WithPrefix("doc"), WithSeparator("/")}, `[["doc/address/city","Boresville"],["doc/address/geo[0]",1.5],["doc/address/geo[1]",2]]`},
+		{"a.b", nil, `[["a\\.b[0]",true]]`},
+		{"a.b", []Option{WithJQPaths(true)}, `[[".[\"a.b\"][0]",true]]`},
+		{"a.b", []Option{WithJSONPointer(true)}, `[["/a.b/0",true]]`},
+		{"n", nil, `[["n",null]]`},
+	}
+
+	for _, test := range tests {
+		pairs, err := FlattenRaw(test.Key, m[test.Key], test.Options...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var aux []tokArray
+
+		for _, p := range pairs {
+			aux = append(aux, tokArray{p.Key, p.Value})
+		}
+
+		b, err := json.Marshal(aux)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Key, test.Expected, b)
+		}
+	}
+
+	if _, err := FlattenRaw("bad", json.RawMessage(`{"a": }`)); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestParseDecoder(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"count": 2, "items": [{"a": 1}, {"b": [true, null]}]} "x"`))
+	dec.UseNumber()
+
+	// Read up to the elements of the array.
+	for i := 0; i < 5; i++ {
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+
+	for dec.More() {
+		pairs, err := ParseDecoder(dec, WithPrefix("item"))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range pairs {
+			keys = append(keys, p.String())
+		}
+	}
+
+	expected := []string{`"item.a": 1`, `"item.b[0]": true`, `"item.b[1]": null`}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	// The rest of the stream is read by the caller.
+	for _, delim := range []json.Delim{']', '}'} {
+		if tok, err := dec.Token(); err != nil || tok != delim {
+			t.Fatalf("expected %s, got %v (%v)", delim, tok, err)
+		}
+	}
+
+	pairs, err := ParseDecoder(dec)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1 || pairs[0].Value != "x" {
+		t.Errorf("expected the next value, got %v", pairs)
+	}
+
+	if _, err := ParseDecoder(dec); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestConvertMapSequence(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a":{"b":1}}{"c":[2]}`, nil, `[{"a.b":1},{"c[0]":2}]`},
+		{`{"a": [1, {"b": 2}]}` + "\n" + `[3] "x" {}`, nil, `[{"a[0]":1,"a[1].b":2},{"[0]":3},{"":"x"},{}]`},
+		{``, nil, `[]`},
+		{`{"b":1,"a":2}{"c":3}`, []Option{WithPreserveOrder(true)}, `[{"b":1,"a":2},{"c":3}]`},
+		{`{"a":1}{"a":2}`, []Option{WithRoot("data")}, `{"data":[{"a":1},{"a":2}]}`},
+		{`{"a":1}{"b":2}`, []Option{WithIndent("  ")}, "[\n  {\n    \"a\": 1\n  },\n  {\n    \"b\": 2\n  }\n]"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, test.Options...)
+
+		if err := enc.ConvertMapSequence(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+
+	enc := NewEncoder(io.Discard, WithStrictKeys(true))
+
+	if err := enc.ConvertMapSequence(strings.NewReader(`{"a":1}{"a":{"b":2},"a":{"b":3}}`)); err == nil {
+		t.Error("expected an error for duplicate keys")
+	}
+
+	if err := enc.ConvertMapSequence(strings.NewReader(`{"a":1}{"b":`)); err == nil {
+		t.Error("expected an error for an incomplete value")
+	}
+}
+
+func TestPairString(t *testing.T) {
+	tests := []struct {
+		Pair     Pair
+		Expected string
+	}{
+		{Pair{Key: "address.city", Value: "Boresville"}, `"address.city": "Boresville"`},
+		{Pair{Key: "n", Value: json.Number("1.50")}, `"n": 1.50`},
+		{Pair{Key: "s", Value: "1.50"}, `"s": "1.50"`},
+		{Pair{Key: "a<b>", Value: nil}, `"a<b>": null`},
+		{Pair{Key: "e", Value: map[string]interface{}{}}, `"e": {}`},
+		{Pair{Key: "c", Value: make(chan int)}, `"c": `},
+	}
+
+	for _, test := range tests {
+		s := test.Pair.String()
+
+		if !strings.HasPrefix(s, test.Expected) {
+			t.Errorf("expected %s, got %s", test.Expected, s)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	input := `{"b": [1, {"c": 2}], "a": null} {"a": 3, "d": {}}`
+
+	keys, err := Keys(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a", "b[0]", "b[1].c", "d"}
+
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	keys, err = Keys(strings.NewReader(`{}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	r := strings.NewReader(`{"example.com": {"ttl": 300}}`)
+
+	pairs, err := Parse(r, WithSeparator("/"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1 || pairs[0].Key != "example.com/ttl" {
+		t.Errorf("expected key example.com/ttl, got %v", pairs)
+	}
+}
+
+func TestArrayKeys(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Opts     []Option
+		Expected []string
+	}{
+		{`{"foo": [1, 2]}`, nil, []string{"foo[0]", "foo[1]"}},
+		{`{"foo": [1], "bar": 2}`, nil, []string{"foo[0]", "bar"}},
+		{`{"a": {"b": [1, 2]}}`, nil, []string{"a.b[0]", "a.b[1]"}},
+		{`{"a": {"b": {"c": [true]}}}`, nil, []string{"a.b.c[0]"}},
+		{`[1, 2]`, nil, []string{"[0]", "[1]"}},
+		{`{"foo": [1]}`, []Option{WithSeparator("/"), WithArrayNotation(DottedBracketNotation)}, []string{"foo/[0]"}},
+		{`{"foo": [1]}`, []Option{WithSeparator("/"), WithArrayNotation(PlainNotation)}, []string{"foo/0"}},
+		{`[1]`, []Option{WithArrayNotation(DottedBracketNotation)}, []string{"[0]"}},
+	}
+
+	for _, test := range tests {
+		pairs, err := Parse(strings.NewReader(test.Input), test.Opts...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pairs) != len(test.Expected) {
+			t.Errorf("%s: expected %d pairs, got %v", test.Input, len(test.Expected), pairs)
+			continue
+		}
+
+		for i, p := range pairs {
+			if p.Key != test.Expected[i] {
+				t.Errorf("%s: expected key %s, got %s", test.Input, test.Expected[i], p.Key)
+			}
+		}
+	}
+}
+
+func TestWideArray(t *testing.T) {
+	const n = 1000
+
+	var b strings.Builder
+
+	b.WriteString(`{"a": [`)
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(strconv.Itoa(i))
+	}
+
+	b.WriteString(`]}`)
+
+	pairs, err := Parse(strings.NewReader(b.String()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != n {
+		t.Fatalf("expected %d pairs, got %d", n, len(pairs))
+	}
+
+	for i, p := range pairs {
+		exp := fmt.Sprintf("a[%d]", i)
+
+		if p.Key != exp || p.Value.(json.Number).String() != strconv.Itoa(i) {
+			t.Errorf("expected %s: %d, got %s", exp, i, p)
+		}
+	}
+}
+
+func TestArrayNotation(t *testing.T) {
+	tests := []struct {
+		Notation ArrayNotation
+		Nested   string
+		Top      string
+	}{
+		{BracketNotation, "a.foo[1]", "[1]"},
+		{DottedBracketNotation, "a.foo.[1]", "[1]"},
+		{PlainNotation, "a.foo.1", "1"},
+	}
+
+	for _, test := range tests {
+		pairs, err := Parse(strings.NewReader(`{"a": {"foo": [1, 2]}}`), WithArrayNotation(test.Notation))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pairs[1].Key != test.Nested {
+			t.Errorf("%s: expected nested key %s, got %s", test.Notation, test.Nested, pairs[1].Key)
+		}
+
+		pairs, err = Parse(strings.NewReader(`[1, 2]`), WithArrayNotation(test.Notation))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pairs[1].Key != test.Top {
+			t.Errorf("%s: expected top-level key %s, got %s", test.Notation, test.Top, pairs[1].Key)
+		}
+	}
+}
+
+func TestIndexWidth(t *testing.T) {
+	tests := []struct {
+		Notation ArrayNotation
+		Width    int
+		Expected string
+	}{
+		{BracketNotation, 3, `[["a[000]",1],["a[001]",2],["b[000][000]",3]]`},
+		{DottedBracketNotation, 2, `[["a.[00]",1],["a.[01]",2],["b.[00].[00]",3]]`},
+		{PlainNotation, 2, `[["a.00",1],["a.01",2],["b.00.00",3]]`},
+		{BracketNotation, 0, `[["a[0]",1],["a[1]",2],["b[0][0]",3]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithArrayNotation(test.Notation), WithIndexWidth(test.Width))
+
+		if err := enc.ConvertArray(strings.NewReader(`{"a": [1, 2], "b": [[3]]}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Notation, test.Expected, s)
+		}
+	}
+
+	// Sorting by key keeps the numeric order of the indices.
+	pairs, err := Parse(strings.NewReader(`{"a": [0,1,2,3,4,5,6,7,8,9,10,11]}`), WithIndexWidth(2))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sortPairs(pairs)
+
+	for i, p := range pairs {
+		if n, _ := p.Number(); n.String() != strconv.Itoa(i) {
+			t.Errorf("expected %d at %s, got %s", i, p.Key, n)
+		}
+	}
+
+	// Indices wider than the width are not truncated.
+	o := newOptions([]Option{WithIndexWidth(2)})
+
+	if k := o.index(123); k != "[123]" {
+		t.Errorf("unexpected index %s", k)
+	}
+
+	b, err := Unflatten(strings.NewReader(`{"a[000]": 1, "a[001]": 2}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"a":[1,2]}` {
+		t.Errorf("unexpected unflattened output %s", s)
+	}
+}
+
+func TestNumberPrecision(t *testing.T) {
+	r := strings.NewReader(`{"id": 12345678901234567890, "x": 0.30000000000000004}`)
+
+	b, err := ConvertMap(r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"id":12345678901234567890,"x":0.30000000000000004}`
+
+	if s := strings.TrimSpace(string(b)); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func TestEmptyValue(t *testing.T) {
+	tests := []struct {
+		Options  []Option
+		Expected string
+	}{
+		{nil, `{"a":null,"b.c":null,"d":1}`},
+		{[]Option{WithEmptyValue("")}, `{"a":"","b.c":"","d":1}`},
+		{[]Option{WithEmptyValue("<empty>"), WithSkipNulls(true)}, `{"a":"\u003cempty\u003e","b.c":"\u003cempty\u003e","d":1}`},
+		{[]Option{WithEmptyValue(""), WithEmptyCollections(true)}, `{"a":{},"b.c":[],"d":1}`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, test.Options...)
+
+		if err := enc.ConvertMap(strings.NewReader(`{"a": {}, "b": {"c": []}, "d": 1}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("expected %s, got %s", test.Expected, s)
+		}
+	}
+}
+
+func TestArrayScalarTypes(t *testing.T) {
+	tests := []jsonTest{
+		{Name: "true", Input: `{"v": true}`, Expected: `[["v",true]]`},
+		{Name: "false", Input: `{"v": false}`, Expected: `[["v",false]]`},
+		{Name: "null", Input: `{"v": null}`, Expected: `[["v",null]]`},
+		{Name: "integer", Input: `{"v": 42}`, Expected: `[["v",42]]`},
+		{Name: "negative integer", Input: `{"v": -7}`, Expected: `[["v",-7]]`},
+		{Name: "zero", Input: `{"v": 0}`, Expected: `[["v",0]]`},
+		{Name: "large integer", Input: `{"v": 12345678901234567890}`, Expected: `[["v",12345678901234567890]]`},
+		{Name: "float", Input: `{"v": 1.5}`, Expected: `[["v",1.5]]`},
+		{Name: "trailing zero", Input: `{"v": 1.0}`, Expected: `[["v",1.0]]`},
+		{Name: "exponent", Input: `{"v": 1e3}`, Expected: `[["v",1e3]]`},
+		{Name: "string", Input: `{"v": "hello"}`, Expected: `[["v","hello"]]`},
+		{Name: "numeric string", Input: `{"v": "42"}`, Expected: `[["v","42"]]`},
+		{Name: "unicode string", Input: `{"v": "h\u00e9llo"}`, Expected: `[["v","héllo"]]`},
+	}
+
+	for _, test := range tests {
+		b, err := ConvertArray(strings.NewReader(test.Input))
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.Name, err)
+		}
+
+		if s := strings.TrimSpace(string(b)); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+
+	// Go values are encoded as encoding/json would encode them.
+	b, err := EncodeArray([]interface{}{true, 1, int64(-2), uint8(3), 2.0, 0.5, float32(0.1), "s", nil})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[["[0]",true],["[1]",1],["[2]",-2],["[3]",3],["[4]",2],["[5]",0.5],["[6]",0.1],["[7]","s"],["[8]",null]]`
+
+	if s := strings.TrimSpace(string(b)); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func TestEmptyCollections(t *testing.T) {
+	input := `{"a": {}, "b": []}`
+
+	b, err := ConvertMap(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"a":null,"b":null}` {
+		t.Errorf("unexpected default output %s", s)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithEmptyCollections(true))
+
+	if err := enc.ConvertMap(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(buf.String()); s != `{"a":{},"b":[]}` {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	b, err = Unflatten(buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"a":{},"b":[]}` {
+		t.Errorf("unexpected unflattened output %s", s)
+	}
+}
+
+func TestParseFunc(t *testing.T) {
+	var keys []string
+
+	r := strings.NewReader(`{"a": 1, "b": {"c": 2}}`)
+
+	err := ParseFunc(r, func(p *Pair) error {
+		keys = append(keys, p.Key)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(keys, ",") != "a,b.c" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+
+	r = strings.NewReader(`{"a": 1, "b": 2}`)
+
+	err = ParseFunc(r, func(p *Pair) error {
+		n++
+		return stop
+	})
+
+	if err != stop {
+		t.Errorf("expected callback error, got %v", err)
+	}
+
+	if n != 1 {
+		t.Errorf("expected decoding to stop after 1 pair, got %d", n)
+	}
+}
+
+func TestConvertLines(t *testing.T) {
+	input := "{\"a\": {\"b\": 1}}\n{\"c\": true}\n[1]\n"
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	if err := enc.ConvertMapLines(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\"a.b\":1}\n{\"c\":true}\n{\"[0]\":1}\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := enc.ConvertArrayLines(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "[[\"a.b\",1]]\n[[\"c\",true]]\n[[\"[0]\",1]]\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	if err := enc.ConvertMapLines(strings.NewReader(`{"a": 1}` + "\n" + `{"b": `)); err == nil {
+		t.Error("expected error for truncated document")
+	}
+}
+
+func TestLineTerminator(t *testing.T) {
+	// CRLF and mixed line endings in the input.
+	input := "{\"a\": {\"b\": 1}}\r\n{\"c\":\r\n true}\n[1]\r\n"
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithLineTerminator("\r\n"))
+
+	if err := enc.ConvertMapLines(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{\"a.b\":1}\r\n{\"c\":true}\r\n{\"[0]\":1}\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	buf.Reset()
+
+	if err := enc.ConvertPairsLines(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "{\"key\":\"a.b\",\"value\":1}\r\n{\"key\":\"c\",\"value\":true}\r\n{\"key\":\"[0]\",\"value\":1}\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// Streamed and indented array output.
+	buf.Reset()
+	enc = NewEncoder(buf, WithLineTerminator("\r\n"), WithIndent(" "))
+
+	if err := enc.ConvertArray(strings.NewReader("[1]\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "[\n [\n  \"[0]\",\n  1\n ]\n]\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+
+	// The input is unaffected by the terminator.
+	buf.Reset()
+	enc = NewEncoder(buf, WithLineTerminator("\n"))
+
+	if err := enc.ConvertArrayLines(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "[[\"a.b\",1]]\n[[\"c\",true]]\n[[\"[0]\",1]]\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		Depth    int
+		Input    string
+		Expected string
+	}{
+		{0, `{"a": {"b": {"c": 1}}}`, `{"a.b.c":1}`},
+		{1, `{"a": {"b": {"c": 1}}}`, `{"a":{"b":{"c":1}}}`},
+		{2, `{"a": {"b": {"c": 1}}}`, `{"a.b":{"c":1}}`},
+		{1, `{"a": [1, {"b": 2}], "c": 3}`, `{"a":[1,{"b":2}],"c":3}`},
+		{1, `[[1, 2], 3]`, `{"[0]":[1,2],"[1]":3}`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithMaxDepth(test.Depth))
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("depth %d: expected %s, got %s", test.Depth, test.Expected, s)
+		}
+	}
+}
+
+func TestArrayWildcardKeys(t *testing.T) {
+	input := `{"hobbies": ["tennis", "coding"], "items": [{"a": 1}, {"a": 2, "b": [[3]]}]}`
+
+	tests := []struct {
+		Options  []Option
+		Expected []string
+	}{
+		{nil, []string{"hobbies[*]", "items[*].a", "items[*].b[*][*]"}},
+		{[]Option{WithArrayNotation(PlainNotation), WithIndexWidth(3)}, []string{"hobbies.*", "items.*.a", "items.*.b.*.*"}},
+		{[]Option{WithJQPaths(true)}, []string{".hobbies[]", ".items[].a", ".items[].b[][]"}},
+		{[]Option{WithJSONPath(true)}, []string{"$.hobbies[*]", "$.items[*].a", "$.items[*].b[*][*]"}},
+		{[]Option{WithArrayWildcardKeys(false)}, []string{"hobbies[0]", "hobbies[1]", "items[0].a", "items[1].a", "items[1].b[0][0]"}},
+	}
+
+	for _, test := range tests {
+		keys, err := Keys(strings.NewReader(input), append([]Option{WithArrayWildcardKeys(true)}, test.Options...)...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(keys, test.Expected) {
+			t.Errorf("expected %v, got %v", test.Expected, keys)
+		}
+	}
+
+	// Later elements overwrite earlier ones in map output.
+	b, err := ConvertMapBytes([]byte(input), WithArrayWildcardKeys(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"hobbies[*]":"coding","items[*].a":2,"items[*].b[*][*]":3}`
+
+	if s := strings.TrimSpace(string(b)); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	if _, err := ConvertMapBytes([]byte(input), WithArrayWildcardKeys(true), WithStrictKeys(true)); err == nil {
+		t.Error("expected an error for duplicate keys")
+	}
+}
+
+func TestMaxArrayExpansion(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a": [1, 2, 3], "b": [1, 2]}`, nil, `[["a[0]",1],["a[1]",2],["a.__truncated__",true],["b[0]",1],["b[1]",2]]`},
+		{`{"a": [[1, 2, 3], {"b": [4]}, [5, [6]], 7]}`, nil, `[["a[0][0]",1],["a[0][1]",2],["a[0].__truncated__",true],["a[1].b[0]",4],["a.__truncated__",true]]`},
+		{`[1, 2, 3]`, nil, `[["[0]",1],["[1]",2],["__truncated__",true]]`},
+		{`[1, 2, 3]`, []Option{WithPrefix("p"), WithArrayNotation(PlainNotation)}, `[["p.0",1],["p.1",2],["p.__truncated__",true]]`},
+		{`{"a": [1, 2, 3]}`, []Option{WithJSONPointer(true)}, `[["/a/0",1],["/a/1",2],["/a/__truncated__",true]]`},
+		{`{"a": [1, 2, 3]}`, []Option{WithFlattenArrays(false)}, `[["a",[1,2,3]]]`},
+		{`{"a": [1, 2, 3]}`, []Option{WithKeyFilter("a[*]")}, `[["a[0]",1],["a[1]",2]]`},
+		{`{"a": [1, 2, 3]}`, []Option{WithMaxArrayExpansion(0)}, `[["a[0]",1],["a[1]",2],["a[2]",3]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append([]Option{WithMaxArrayExpansion(2)}, test.Options...)...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+
+	// Skipped elements must still be well-formed.
+	if _, err := Parse(strings.NewReader(`[1, 2, {"a": ]]`), WithMaxArrayExpansion(2)); err == nil {
+		t.Error("expected an error for invalid skipped elements")
+	}
+
+	m, err := Flatten(map[string]interface{}{"a": []int{1, 2, 3}}, WithMaxArrayExpansion(1))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(m, map[string]interface{}{"a[0]": json.Number("1"), "a.__truncated__": true}) {
+		t.Errorf("unexpected map %v", m)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	r := strings.NewReader(`{"address": {"city": x}}`)
+
+	_, err := Parse(r)
+
+	perr, ok := err.(*ParseError)
+
+	if !ok {
+		t.Fatalf("expected *ParseError, got %T: %v", err, err)
+	}
+
+	if perr.Path != "address.city" {
+		t.Errorf("expected path address.city, got %q", perr.Path)
+	}
+
+	if perr.Offset != 19 {
+		t.Errorf("expected offset 19, got %d", perr.Offset)
+	}
+
+	if !strings.HasPrefix(err.Error(), `flatjson: invalid token at offset 19 (path "address.city")`) {
+		t.Errorf("unexpected message %q", err)
+	}
+
+	_, err = Parse(strings.NewReader(`{"a": [1, `))
+
+	if perr, ok := err.(*ParseError); !ok || perr.Err != io.ErrUnexpectedEOF {
+		t.Errorf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestUnbalanced(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Tokens []json.Token
+	}{
+		{"close without open", []json.Token{rbrace}},
+		{"close array as map", []json.Token{lsquare, "a", rbrace}},
+		{"close map as array", []json.Token{lbrace, "a", json.Number("1"), rsquare}},
+		{"close after key", []json.Token{lbrace, "a", rbrace}},
+		{"non-string key", []json.Token{lbrace, json.Number("1"), json.Number("2"), rbrace}},
+		{"kept whole", []json.Token{lbrace, "a", lsquare, json.Number("1"), rbrace, rbrace}},
+		{"kept whole without value", []json.Token{lbrace, "a", lbrace, "b", rbrace, rbrace}},
+	}
+
+	o := newOptions([]Option{WithFlattenArrays(false), WithMaxDepth(2)})
+
+	for _, test := range tests {
+		p := newParser(context.Background(), &tokenSlice{toks: test.Tokens}, &o)
+
+		err := parseValue(p, func(*Pair) error { return nil })
+
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%s: expected *ParseError, got %v", test.Name, err)
+		}
+
+		if strings.HasPrefix(test.Name, "close") && !errors.Is(err, ErrUnbalanced) {
+			t.Errorf("%s: expected ErrUnbalanced, got %v", test.Name, err)
+		}
+	}
+
+	// Delimiters are checked by the decoder before reaching the parser.
+	if _, err := Parse(strings.NewReader(`{"a": [1}`)); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestTrailingData(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Offset int64
+	}{
+		{`{"a": 1} trailing junk`, 8},
+		{`{"a": 1}]`, 8},
+		{`[1] {"b": 2} }`, 12},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.Input))
+
+		var terr *TrailingDataError
+
+		if !errors.As(err, &terr) {
+			t.Errorf("%s: expected trailing data error, got %v", test.Input, err)
+			continue
+		}
+
+		if terr.Offset != test.Offset {
+			t.Errorf("%s: expected offset %d, got %d", test.Input, test.Offset, terr.Offset)
+		}
+
+		expected := fmt.Sprintf("flatjson: unexpected trailing data at offset %d", test.Offset)
+
+		if err.Error() != expected {
+			t.Errorf("%s: expected %q, got %q", test.Input, expected, err)
+		}
+	}
+
+	// In lines mode the values before the trailing data are written.
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	err := enc.ConvertMapLines(strings.NewReader("{\"a\": 1}\n{\"b\": 2}\njunk"))
+
+	var terr *TrailingDataError
+
+	if !errors.As(err, &terr) {
+		t.Errorf("expected trailing data error, got %v", err)
+	}
+
+	if s := buf.String(); s != "{\"a\":1}\n{\"b\":2}\n" {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	// An invalid first value is not trailing data.
+	if _, err := Parse(strings.NewReader(`junk`)); errors.As(err, &terr) {
+		t.Errorf("unexpected trailing data error %s", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Options []Option
+		Err     bool
+	}{
+		{`{"a": 1, "b": 2}`, []Option{WithMaxPairs(2)}, false},
+		{`{"a": 1, "b": 2, "c": 3}`, []Option{WithMaxPairs(2)}, true},
+		{`{"a": 1} {"b": 2} {"c": 3}`, []Option{WithMaxPairs(2)}, true},
+		{`{"a": 1}`, []Option{WithMaxInputBytes(8)}, false},
+		{`{"a": 1} `, []Option{WithMaxInputBytes(8)}, true},
+		{`{"a": [1, 2, 3, 4]}`, []Option{WithMaxInputBytes(10)}, true},
+		{`{"a": {"b": [1, 2, 3]}}`, []Option{WithMaxInputBytes(10), WithMaxDepth(1)}, true},
+		{`{"a": 1} {"b": 2}`, []Option{WithMaxInputBytes(10)}, true},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.Input), test.Options...)
+
+		if !test.Err {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", test.Input, err)
+			}
+
+			continue
+		}
+
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%s: expected limit error, got %v", test.Input, err)
+			continue
+		}
+
+		if !strings.HasPrefix(err.Error(), "flatjson: limit exceeded") {
+			t.Errorf("%s: unexpected error %s", test.Input, err)
+		}
+	}
+
+	// The limit applies to the whole input of a decoder.
+	dec := NewDecoder(strings.NewReader(`1 2 3`), WithMaxPairs(2))
+
+	for i := 0; i < 2; i++ {
+		if _, err := dec.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := dec.Next(); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected limit error, got %v", err)
+	}
+
+	// Reading past an exceeded input limit keeps returning the error.
+	dec = NewDecoder(strings.NewReader(`{"a": 1, "b": 2, "c": 3}`), WithMaxInputBytes(5))
+
+	for i := 0; i < 3; i++ {
+		if _, err := dec.Next(); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("expected limit error, got %v", err)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	v := map[string]interface{}{
+		"name": "Bob Smith",
+		"address": map[string]interface{}{
+			"city":    "Boresville",
+			"zipcode": 13943,
+		},
+	}
+
+	m, err := Flatten(v)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"name":            "Bob Smith",
+		"address.city":    "Boresville",
+		"address.zipcode": json.Number("13943"),
+	}
+
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %v, got %v", expected, m)
+	}
+
+	m, err = Flatten([]interface{}{"tennis", true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[string]interface{}{
+		"[0]": "tennis",
+		"[1]": true,
+	}
+
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %v, got %v", expected, m)
+	}
+}
+
+func TestStrictKeys(t *testing.T) {
+	input := `{"a.b": 1, "a": {"b": 2}}`
+
+	if _, err := ConvertMap(strings.NewReader(input)); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	enc := NewEncoder(io.Discard, WithStrictKeys(true), WithKeyEscaping(false))
+
+	err := enc.ConvertMap(strings.NewReader(input))
+
+	if err == nil || !strings.Contains(err.Error(), `"a.b"`) {
+		t.Errorf("expected duplicate key error, got %v", err)
+	}
+
+	if err := enc.ConvertArray(strings.NewReader(input)); err != nil {
+		t.Errorf("array output should allow duplicate keys, got %s", err)
+	}
+
+	if _, err := Flatten(map[string]interface{}{"a.b": 1, "a": map[string]int{"b": 2}}, WithStrictKeys(true), WithKeyEscaping(false)); err == nil {
+		t.Error("expected duplicate key error from Flatten")
+	}
+}
+
+func TestDedupeKeys(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a.b": 1, "a": {"b": 2}}`, nil, `{"a.b":1,"a.b#1":2}`},
+		{`{"a.b": 1, "a": {"b": 2}, "A": {"B": 3}}`, []Option{WithKeyFunc(strings.ToLower)}, `{"a.b":1,"a.b#1":2,"a.b#2":3}`},
+		{`{"x": 1, "X": 2, "x#1": 3}`, []Option{WithKeyFunc(strings.ToLower)}, `{"x":1,"x#2":2,"x#1":3}`},
+		{`{"a.b": 1, "a": {"b": 2}}`, []Option{WithStrictKeys(true)}, `{"a.b":1,"a.b#1":2}`},
+		{`{"a": 1, "b": 2}`, nil, `{"a":1,"b":2}`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append([]Option{WithKeyEscaping(false), WithDedupeKeys(true), WithPreserveOrder(true)}, test.Options...)...)
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+
+	m, err := Flatten(map[string]interface{}{"a.b": 1, "a": map[string]int{"b": 2}}, WithKeyEscaping(false), WithDedupeKeys(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m) != 2 || m["a.b#1"] == nil {
+		t.Errorf("expected both values, got %v", m)
+	}
+}
+
+func TestKeyEscaping(t *testing.T) {
+	input := `{"a.b": 1, "c\\d": 3, "e[0]": 4, "a": {"b": 2}}`
+
+	pairs, err := Parse(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`a\.b`, `c\\d`, `e\[0\]`, `a.b`}
+
+	for i, p := range pairs {
+		if p.Key != expected[i] {
+			t.Errorf("expected key %s, got %s", expected[i], p.Key)
+		}
+	}
+
+	pairs, err = Parse(strings.NewReader(input), WithKeyEscaping(false))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pairs[0].Key != "a.b" || pairs[2].Key != "e[0]" {
+		t.Errorf("expected unescaped keys, got %v", pairs)
+	}
+}
+
+func TestFlattenArrays(t *testing.T) {
+	tests := []jsonTest{
+		{
+			Name:     "array value",
+			Input:    `{"hobbies": ["a", "b"], "address": {"city": "x"}}`,
+			Expected: `{"address.city":"x","hobbies":["a","b"]}`,
+		},
+		{
+			Name:     "nested array value",
+			Input:    `{"a": {"b": [{"c": 1}]}}`,
+			Expected: `{"a.b":[{"c":1}]}`,
+		},
+		{
+			Name:     "top-level array",
+			Input:    `[["a"], "b"]`,
+			Expected: `{"[0]":["a"],"[1]":"b"}`,
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithFlattenArrays(false))
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+}
+
+func TestArraysAsArrays(t *testing.T) {
+	tests := []jsonTest{
+		{
+			Name:     "array of maps",
+			Input:    `{"items": [{"a": 1}, {"b": {"c": true}}]}`,
+			Expected: `{"items":[{"a":1},{"b.c":true}]}`,
+		},
+		{
+			Name:     "nested arrays",
+			Input:    `{"a": {"b": [[{"c": {"d": 1}}], 2, "x", null]}}`,
+			Expected: `{"a.b":[[{"c.d":1}],2,"x",null]}`,
+		},
+		{
+			Name:     "arrays within elements",
+			Input:    `{"a": [{"b": [{"c": {"d": 1}}], "e": {}}]}`,
+			Expected: `{"a":[{"b":[{"c.d":1}],"e":null}]}`,
+		},
+		{
+			Name:     "empty",
+			Input:    `{"a": [], "b": [{}]}`,
+			Expected: `{"a":[],"b":[{}]}`,
+		},
+		{
+			Name:     "top-level array",
+			Input:    `[{"a": {"b": 1}}, [{"c": {"d": 2}}]]`,
+			Expected: `{"[0].a.b":1,"[1]":[{"c.d":2}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithArraysAsArrays(true))
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+
+	// The prefix only applies to the enclosing key.
+	pairs, err := Parse(strings.NewReader(`{"a": [{"b": {"c": 1}}]}`), WithArraysAsArrays(true), WithPrefix("p"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := pairs[0].String(); s != `"p.a": [{"b.c":1}]` {
+		t.Errorf("expected prefixed key, got %s", s)
+	}
+}
+
+func TestConvertArrayStream(t *testing.T) {
+	options := [][]Option{
+		nil,
+		{WithIndent("  ")},
+		{WithIndent("\t"), WithPairObjects(true)},
+		{WithPathSegments(true), WithTypedValues(true)},
+		{WithKeyFilter("x")},
+	}
+
+	for _, input := range []string{benchDoc, `{"a": "<b>"} [1, [2]]`, `[]`} {
+		for _, opts := range options {
+			buf := bytes.NewBuffer(nil)
+			enc := NewEncoder(buf, opts...)
+
+			if err := enc.ConvertArray(strings.NewReader(input)); err != nil {
+				t.Fatal(err)
+			}
+
+			// Buffered output of the same pairs.
+			pairs, err := parseJSON(context.Background(), strings.NewReader(input), &enc.opts)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exp := bytes.NewBuffer(nil)
+			enc.Reset(exp)
+
+			if err := enc.writeArray(pairs); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != exp.String() {
+				t.Errorf("expected %s, got %s", exp, buf)
+			}
+		}
+	}
+
+	// Small outputs are not written if an error occurs.
+	buf := bytes.NewBuffer(nil)
+
+	if err := NewEncoder(buf).ConvertArray(strings.NewReader(`{"a": 1, "b": }`)); err == nil {
+		t.Error("expected an error")
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %s", buf)
+	}
+}
+
+func TestConvertPairsLines(t *testing.T) {
+	input := `{"a": {"b": 1}, "c": [true]} {"d": "x"}`
+
+	expected := `{"key":"a.b","value":1}
+{"key":"c[0]","value":true}
+{"key":"d","value":"x"}
+`
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithIndent("  "))
+
+	if err := enc.ConvertPairsLines(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buf.String(); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func TestPairObjects(t *testing.T) {
+	tests := []struct {
+		Options  []Option
+		Expected string
+	}{
+		{[]Option{WithPairObjects(true)}, `[{"key":"a.b","value":1},{"key":"c","value":"x"}]`},
+		{[]Option{WithPairObjects(true, "k", "v")}, `[{"k":"a.b","v":1},{"k":"c","v":"x"}]`},
+		{[]Option{WithPairObjects(true, "name")}, `[{"name":"a.b","value":1},{"name":"c","value":"x"}]`},
+		{[]Option{WithPairObjects(false, "k", "v")}, `[["a.b",1],["c","x"]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, test.Options...)
+
+		if err := enc.ConvertArray(strings.NewReader(`{"a": {"b": 1}, "c": "x"}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("expected %s, got %s", test.Expected, s)
+		}
+	}
+}
+
+func TestBOM(t *testing.T) {
+	inputs := []string{
+		"\xef\xbb\xbf{\"a\": 1}",
+		"\xef\xbb\xbf \n\t{\"a\": 1}\r\n ",
+		"\n  {\"a\": 1}  \n",
+	}
+
+	for _, input := range inputs {
+		b, err := ConvertMap(strings.NewReader(input))
+
+		if err != nil {
+			t.Errorf("%q: %s", input, err)
+			continue
+		}
+
+		if s := strings.TrimSpace(string(b)); s != `{"a":1}` {
+			t.Errorf("%q: unexpected output %s", input, s)
+		}
+	}
+
+	b, err := Unflatten(strings.NewReader("\xef\xbb\xbf{\"a.b\": 1}"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"a":{"b":1}}` {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestPathSegments(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"address": {"city": "Boresville"}, "hobbies": ["tennis"]}`, nil, `[[["address","city"],"Boresville"],[["hobbies",0],"tennis"]]`},
+		{`{"a.b": [[1], {}]}`, nil, `[[["a.b",0,0],1],[["a.b",1],null]]`},
+		{`[1]`, []Option{WithPrefix("p")}, `[[["p",0],1]]`},
+		{`42`, nil, `[[[],42]]`},
+		{`{"a": 1}`, []Option{WithPairObjects(true)}, `[{"key":["a"],"value":1}]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithPathSegments(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestConvertContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	enc := NewEncoder(io.Discard)
+
+	if err := enc.ConvertMapContext(ctx, strings.NewReader(`{"a": 1}`)); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if err := enc.ConvertArrayContext(ctx, strings.NewReader(`{"a": 1}`)); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if err := enc.ConvertMapContext(context.Background(), strings.NewReader(`{"a": 1}`)); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestSortKeys(t *testing.T) {
+	inputs := []string{
+		`{"b": 1, "a": {"d": 2, "c": 3}}`,
+		`{"a": {"c": 3, "d": 2}, "b": 1}`,
+	}
+
+	expected := `[["a.c",3],["a.d",2],["b",1]]`
+
+	for i := 0; i < 3; i++ {
+		for _, input := range inputs {
+			buf := bytes.NewBuffer(nil)
+			enc := NewEncoder(buf, WithSortKeys(true))
+
+			if err := enc.ConvertArray(strings.NewReader(input)); err != nil {
+				t.Fatal(err)
+			}
+
+			if s := strings.TrimSpace(buf.String()); s != expected {
+				t.Errorf("expected %s, got %s", expected, s)
+			}
+		}
+	}
+}
+
+func TestPreserveOrder(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"z": 1, "a": {"y": 2, "b": 3}, "m": [4]}`, nil, `{"z":1,"a.y":2,"a.b":3,"m[0]":4}`},
+		{`{"z": 1, "a": 2}`, []Option{WithSortKeys(true)}, `{"a":2,"z":1}`},
+		{`{"b": 1, "a": 2, "b": 3}`, nil, `{"b":3,"a":2}`},
+		{`{}`, nil, `{}`},
+		{`{"z": "<", "a": 1}`, []Option{WithIndent(" ")}, "{\n \"z\": \"\\u003c\",\n \"a\": 1\n}"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithPreserveOrder(true))...)
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithIndent("  "))
+
+	if err := enc.ConvertMap(strings.NewReader(`{"a": {"b": 1}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buf.String(); s != "{\n  \"a.b\": 1\n}\n" {
+		t.Errorf("unexpected output %q", s)
+	}
+
+	buf.Reset()
+
+	if err := enc.ConvertArray(strings.NewReader(`{"a": 1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buf.String(); s != "[\n  [\n    \"a\",\n    1\n  ]\n]\n" {
+		t.Errorf("unexpected output %q", s)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Opts     []Option
+		Expected []string
+	}{
+		{`{"name": "Bob", "address": {"city": "x"}}`, nil, []string{"user.name", "user.address.city"}},
+		{`{"a": {"hobbies": ["tennis"]}}`, nil, []string{"user.a.hobbies[0]"}},
+		{`[1, 2]`, nil, []string{"user[0]", "user[1]"}},
+		{`[1]`, []Option{WithArrayNotation(PlainNotation)}, []string{"user.0"}},
+		{`{"name": "Bob"}`, []Option{WithSeparator("/")}, []string{"user/name"}},
+	}
+
+	for _, test := range tests {
+		pairs, err := Parse(strings.NewReader(test.Input), append(test.Opts, WithPrefix("user"))...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pairs) != len(test.Expected) {
+			t.Fatalf("%s: expected %d pairs, got %d", test.Input, len(test.Expected), len(pairs))
+		}
+
+		for i, p := range pairs {
+			if p.Key != test.Expected[i] {
+				t.Errorf("%s: expected key %s, got %s", test.Input, test.Expected[i], p.Key)
+			}
+		}
+	}
+}
+
+func TestTopLevelScalar(t *testing.T) {
+	tests := []jsonTest{
+		{Name: "number", Input: `42`, Expected: `{"":42}`},
+		{Name: "string", Input: ` "hello" `, Expected: `{"":"hello"}`},
+		{Name: "bool", Input: `true`, Expected: `{"":true}`},
+		{Name: "null", Input: `null`, Expected: `{"":null}`},
+	}
+
+	for _, test := range tests {
+		b, err := ConvertMap(strings.NewReader(test.Input))
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.Name, err)
+		}
+
+		if s := strings.TrimSpace(string(b)); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+
+	b, err := ConvertArray(strings.NewReader(`42`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `[["",42]]` {
+		t.Errorf("expected [[\"\",42]], got %s", s)
+	}
+
+	pairs, err := Parse(strings.NewReader(`42`), WithPrefix("answer"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1 || pairs[0].Key != "answer" {
+		t.Errorf("expected a single pair with key answer, got %v", pairs)
+	}
+}
+
+func TestDepthPrefix(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Expected string
+	}{
+		{`{"name": "Bob", "address": {"city": "Boresville"}, "tags": [[1]], "e": {}}`, `[["1:name","Bob"],["2:address.city","Boresville"],["3:tags[0][0]",1],["1:e",null]]`},
+		{`42`, `[["0:",42]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithDepthPrefix(true))
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestValueFunc(t *testing.T) {
+	redact := func(key string, value interface{}) interface{} {
+		if strings.HasSuffix(key, "ssn") {
+			return "REDACTED"
+		}
+
+		return value
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithValueFunc(redact))
+
+	if err := enc.ConvertMap(strings.NewReader(`{"name": "Bob", "id": {"ssn": "123-45-6789"}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"id.ssn":"REDACTED","name":"Bob"}`
+
+	if s := strings.TrimSpace(buf.String()); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	input := `{"Name": "Bob", "Home Address": {"City": "x"}}`
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithKeyFunc(func(key string) string {
+		return strings.Replace(strings.ToLower(key), " ", "_", -1)
+	}))
+
+	if err := enc.ConvertMap(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"home_address.city":"x","name":"Bob"}`
+
+	if s := strings.TrimSpace(buf.String()); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	enc = NewEncoder(io.Discard, WithKeyFunc(strings.ToLower), WithStrictKeys(true))
+
+	if err := enc.ConvertMap(strings.NewReader(`{"a": 1, "A": 2}`)); err == nil {
+		t.Error("expected duplicate key error")
+	}
+}
+
+func TestNestedCollections(t *testing.T) {
+	tests := []jsonTest{
+		{
+			Name:     "array of objects",
+			Input:    `{"items": [{"id": 1}, {"id": 2}]}`,
+			Expected: `[["items[0].id",1],["items[1].id",2]]`,
+		},
+		{
+			Name:     "array of objects followed by key",
+			Input:    `{"items": [{"id": 1, "tags": ["a"]}, {"id": 2}], "n": 2}`,
+			Expected: `[["items[0].id",1],["items[0].tags[0]","a"],["items[1].id",2],["n",2]]`,
+		},
+		{
+			Name:     "object after nested object",
+			Input:    `{"a": {"b": 1}, "c": 2, "d": 3}`,
+			Expected: `[["a.b",1],["c",2],["d",3]]`,
+		},
+		{
+			Name:     "object after array",
+			Input:    `{"a": [1, 2], "b": {"c": 3}, "d": 4}`,
+			Expected: `[["a[0]",1],["a[1]",2],["b.c",3],["d",4]]`,
+		},
+		{
+			Name:     "top-level array of objects",
+			Input:    `[{"a": 1}, {"b": {"c": 2}}, 3]`,
+			Expected: `[["[0].a",1],["[1].b.c",2],["[2]",3]]`,
+		},
+		{
+			Name:     "objects in arrays in objects",
+			Input:    `{"a": [{"b": [{"c": 1}, {"d": 2}]}], "e": 3}`,
+			Expected: `[["a[0].b[0].c",1],["a[0].b[1].d",2],["e",3]]`,
+		},
+		{
+			Name:     "scalars after array of objects",
+			Input:    `{"a": [{"x": 1}], "b": true, "c": null}`,
+			Expected: `[["a[0].x",1],["b",true],["c",null]]`,
+		},
+		{
+			Name:     "array of arrays",
+			Input:    `{"a": [[1, 2], [3]]}`,
+			Expected: `[["a[0][0]",1],["a[0][1]",2],["a[1][0]",3]]`,
+		},
+	}
+
+	for _, test := range tests {
+		b, err := ConvertArray(strings.NewReader(test.Input))
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.Name, err)
+		}
+
+		if s := strings.TrimSpace(string(b)); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+}
+
+func TestNestedEmptyCollections(t *testing.T) {
+	tests := []struct {
+		Input string
+		Null  string
+		Kept  string
+	}{
+		{`{}`, `[]`, `[]`},
+		{`[]`, `[]`, `[]`},
+		{`{"a": {}}`, `[["a",null]]`, `[["a",{}]]`},
+		{`{"a": {"b": {}}}`, `[["a.b",null]]`, `[["a.b",{}]]`},
+		{`{"a": {"b": {}, "c": 1}}`, `[["a.b",null],["a.c",1]]`, `[["a.b",{}],["a.c",1]]`},
+		{`[[]]`, `[["[0]",null]]`, `[["[0]",[]]]`},
+		{`[[], [[]], {}]`, `[["[0]",null],["[1][0]",null],["[2]",null]]`, `[["[0]",[]],["[1][0]",[]],["[2]",{}]]`},
+		{`{"a": [{}, []], "b": {"c": []}, "d": 1}`, `[["a[0]",null],["a[1]",null],["b.c",null],["d",1]]`, `[["a[0]",{}],["a[1]",[]],["b.c",[]],["d",1]]`},
+	}
+
+	for _, test := range tests {
+		b, err := ConvertArray(strings.NewReader(test.Input))
+
+		if err != nil {
+			t.Fatalf("%s: %s", test.Input, err)
+		}
+
+		if s := strings.TrimSpace(string(b)); s != test.Null {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Null, s)
+		}
+
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithEmptyCollections(true))
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatalf("%s: %s", test.Input, err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Kept {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Kept, s)
+		}
+	}
+}
+
+func TestIncludeContainers(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a": {"b": 1}}`, nil, `[["a","object"],["a.b",1]]`},
+		{`{"a": [{"b": 1}, []], "c": {}}`, nil, `[["a","array"],["a[0]","object"],["a[0].b",1],["a[1]","array"],["c","object"]]`},
+		{`[[1]]`, nil, `[["[0]","array"],["[0][0]",1]]`},
+		{`{"a": {"b": {"c": 1}}}`, []Option{WithMaxDepth(2)}, `[["a","object"],["a.b",{"c":1}]]`},
+		{`{"a": [1]}`, []Option{WithFlattenArrays(false)}, `[["a",[1]]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithIncludeContainers(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestSkipNulls(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a": null, "b": 1, "c": [null, 2]}`, nil, `[["b",1],["c[1]",2]]`},
+		{`{"a": {}, "b": [], "c": {"d": []}}`, nil, `[]`},
+		{`{"a": {}, "b": [], "c": null}`, []Option{WithEmptyCollections(true)}, `[["a",{}],["b",[]]]`},
+		{`null`, nil, `[]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithSkipNulls(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a": "  Bob \t", "b": [" x", "y\n"], "c": 1, "d": true}`, nil, `[["a","Bob"],["b[0]","x"],["b[1]","y"],["c",1],["d",true]]`},
+		{`{"  a ": "   "}`, nil, `[["  a ",""]]`},
+		{`" top "`, nil, `[["","top"]]`},
+		{`{"a": " x "}`, []Option{WithValueFunc(func(k string, v interface{}) interface{} {
+			return "[" + v.(string) + "]"
+		})}, `[["a","[x]"]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithTrimSpace(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestTypedValues(t *testing.T) {
+	tests := []jsonTest{
+		{
+			Name:     "scalars",
+			Input:    `{"zip": 13943, "code": "13943", "ok": true, "none": null}`,
+			Expected: `{"code":{"type":"string","value":"13943"},"none":{"type":"null","value":null},"ok":{"type":"boolean","value":true},"zip":{"type":"number","value":13943}}`,
+		},
 		{
-			"name": "Bob Smith",
-			"address": {
-				"street": "123 Main Street",
-				"city": "Boresville",
-				"zipcode": 13943
-			},
-			"hobbies": ["tennis", "coding", "cooking"]
+			Name:     "empty collection",
+			Input:    `{"a": [], "b": [1.5]}`,
+			Expected: `{"a":{"type":"null","value":null},"b[0]":{"type":"number","value":1.5}}`,
+		},
+		{
+			Name:     "top-level scalar",
+			Input:    `"x"`,
+			Expected: `{"":{"type":"string","value":"x"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithTypedValues(true))
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, s)
+		}
+	}
+
+	// Array output and the values of parsed pairs.
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithTypedValues(true), WithEmptyCollections(true))
+
+	if err := enc.ConvertArray(strings.NewReader(`{"a": {}, "b": "1"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `[["a",{"type":"object","value":{}}],["b",{"type":"string","value":"1"}]]`
+
+	if s := strings.TrimSpace(buf.String()); s != exp {
+		t.Errorf("expected %s, got %s", exp, s)
+	}
+
+	pairs, err := Parse(strings.NewReader(`{"b": "1"}`), WithTypedValues(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pairs[0].Value != "1" {
+		t.Errorf("expected parsed value to be unchanged, got %v", pairs[0].Value)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	tests := []struct {
+		Options  []Option
+		Array    bool
+		Expected string
+	}{
+		{nil, false, `{"data":{"a.b":1,"c":"x"}}`},
+		{[]Option{WithPrefix("p")}, false, `{"data":{"p.a.b":1,"p.c":"x"}}`},
+		{nil, true, `{"data":[["a.b",1],["c","x"]]}`},
+		{[]Option{WithMetaHeader("_meta")}, false, `{"_meta":{"sep":".","arrayNotation":"bracket","keyEscaping":true},"data":{"data":{"a.b":1,"c":"x"}}}`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithRoot("data"))...)
+
+		convert := enc.ConvertMap
+
+		if test.Array {
+			convert = enc.ConvertArray
+		}
+
+		if err := convert(strings.NewReader(`{"a": {"b": 1}, "c": "x"}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("expected %s, got %s", test.Expected, s)
+		}
+	}
+}
+
+func TestValidateKeys(t *testing.T) {
+	tests := []struct {
+		Input string
+		Error string
+	}{
+		{`{"a": {"b c": 1}, "d\u00e9": [2]}`, ""},
+		{`{"a": {"b\u0000c": 1}}`, `flatjson: key "a.b\x00c" contains control character U+0000`},
+		{`{"a\tb": 1}`, `flatjson: key "a\tb" contains control character U+0009`},
+		{`[{"x": 1}, {"\u001f": 2}]`, `flatjson: key "[1].\x1f" contains control character U+001F`},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(strings.NewReader(test.Input), WithValidateKeys(true))
+
+		if test.Error == "" && err != nil {
+			t.Errorf("%s: unexpected error %s", test.Input, err)
+		}
+
+		if test.Error != "" && (err == nil || err.Error() != test.Error) {
+			t.Errorf("%s: expected error %s, got %v", test.Input, test.Error, err)
+		}
+	}
+
+	// Keys are not validated by default.
+	if _, err := Parse(strings.NewReader(`{"a\u0000": 1}`)); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestStringValues(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"a": 13943, "b": 1.5e3, "c": true, "d": null, "e": "x"}`, nil, `{"a":"13943","b":"1.5e3","c":"true","d":"","e":"x"}`},
+		{`{"a": {"b": [1]}, "c": []}`, []Option{WithMaxDepth(1), WithEmptyCollections(true)}, `{"a":{"b":[1]},"c":[]}`},
+		{`{"a": 1}`, []Option{WithValueFunc(func(k string, v interface{}) interface{} { return 2.5 })}, `{"a":"2.5"}`},
+		{`{"a": 1, "b": "1"}`, []Option{WithTypedValues(true)}, `{"a":{"type":"number","value":"1"},"b":{"type":"string","value":"1"}}`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithStringValues(true))...)
+
+		if err := enc.ConvertMap(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithStringValues(true))
+
+	if err := enc.ConvertArray(strings.NewReader(`[1, false]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(buf.String()); s != `[["[0]","1"],["[1]","false"]]` {
+		t.Errorf("expected string values, got %s", s)
+	}
+}
+
+func TestRequireObjectRoot(t *testing.T) {
+	tests := []struct {
+		Input string
+		Error string
+	}{
+		{`{"a": [1]}`, ""},
+		{`{} {"b": {}}`, ""},
+		{`[{"a": 1}]`, "flatjson: expected object root, got array"},
+		{`"x"`, "flatjson: expected object root, got string"},
+		{`{"a": 1} 2`, "flatjson: expected object root, got number"},
+		{`null`, "flatjson: expected object root, got null"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		err := NewEncoder(buf, WithRequireObjectRoot(true)).ConvertMap(strings.NewReader(test.Input))
+
+		if test.Error == "" && err != nil {
+			t.Errorf("%s: unexpected error %s", test.Input, err)
+		}
+
+		if test.Error != "" && (err == nil || err.Error() != test.Error) {
+			t.Errorf("%s: expected error %s, got %v", test.Input, test.Error, err)
+		}
+	}
+
+	if _, err := Flatten([]int{1}, WithRequireObjectRoot(true)); err == nil {
+		t.Error("expected an error for a slice")
+	}
+}
+
+func TestNoScientificNotation(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithNoScientificNotation(true))
+
+	if err := enc.ConvertMap(strings.NewReader(`{"a": 1e20, "b": [2.5E-3, 10]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"a":100000000000000000000,"b[0]":0.0025,"b[1]":10}`
+
+	if s := strings.TrimSpace(buf.String()); s != exp {
+		t.Errorf("expected %s, got %s", exp, s)
+	}
+
+	buf.Reset()
+
+	if err := enc.EncodeMap(map[string]float64{"a": 1e21, "b": 1e-7}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp = `{"a":1000000000000000000000,"b":0.0000001}`
+
+	if s := strings.TrimSpace(buf.String()); s != exp {
+		t.Errorf("expected %s, got %s", exp, s)
+	}
+}
+
+func TestMaxKeyLength(t *testing.T) {
+	input := `{"address": {"street_name": 1, "street_number": 2}, "id": 3}`
+
+	pairs, err := Parse(strings.NewReader(input), WithMaxKeyLength(12))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pairs[0].Key == pairs[1].Key {
+		t.Errorf("expected distinct keys, got %s", pairs[0].Key)
+	}
+
+	for _, p := range pairs[:2] {
+		if len(p.Key) != 12 || !strings.HasPrefix(p.Key, "add~") {
+			t.Errorf("unexpected key %s", p.Key)
+		}
+	}
+
+	if pairs[2].Key != "id" {
+		t.Errorf("expected id, got %s", pairs[2].Key)
+	}
+
+	if k := TruncateKey("héllo.wörld", 10); k != "h"+TruncateKey("héllo.wörld", 9) {
+		t.Errorf("unexpected key %s", k)
+	}
+
+	if k := TruncateKey("héllo.wörld", 4); len(k) != 4 {
+		t.Errorf("unexpected key %s", k)
+	}
+
+	if k := TruncateKey("héllo.wörld", -1); k != "héllo.wörld" {
+		t.Errorf("expected the key unchanged, got %s", k)
+	}
+
+	// A custom shortener can record the original keys.
+	shortened := make(map[string]string)
+
+	_, err = Parse(strings.NewReader(input), WithMaxKeyLength(10), WithKeyShortener(func(key string, n int) string {
+		short := key[:n]
+		shortened[short] = key
+		return short
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shortened["address.st"] != "address.street_number" {
+		t.Errorf("unexpected mapping %v", shortened)
+	}
+}
+
+func TestNestedCollectionsNotation(t *testing.T) {
+	input := `{"items": [{"id": 1}, {"id": [2]}]}`
+
+	tests := []struct {
+		Notation ArrayNotation
+		Expected string
+	}{
+		{BracketNotation, `[["items[0].id",1],["items[1].id[0]",2]]`},
+		{DottedBracketNotation, `[["items.[0].id",1],["items.[1].id.[0]",2]]`},
+		{PlainNotation, `[["items.0.id",1],["items.1.id.0",2]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithArrayNotation(test.Notation))
+
+		if err := enc.ConvertArray(strings.NewReader(input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Notation, test.Expected, s)
+		}
+	}
+}
+
+func TestNestedArrays(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Notation ArrayNotation
+		Expected string
+	}{
+		{`[[1, 2], [3, 4]]`, BracketNotation, `[["[0][0]",1],["[0][1]",2],["[1][0]",3],["[1][1]",4]]`},
+		{`[[[1, 2]], [[3], [4]]]`, BracketNotation, `[["[0][0][0]",1],["[0][0][1]",2],["[1][0][0]",3],["[1][1][0]",4]]`},
+		{`{"a": [[[1]], [[2, 3]]], "b": 4}`, BracketNotation, `[["a[0][0][0]",1],["a[1][0][0]",2],["a[1][0][1]",3],["b",4]]`},
+		{`[[[1]], 2]`, DottedBracketNotation, `[["[0].[0].[0]",1],["[1]",2]]`},
+		{`[[[1]], 2]`, PlainNotation, `[["0.0.0",1],["1",2]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithArrayNotation(test.Notation))
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	const depth = 60
+
+	var (
+		in  strings.Builder
+		key strings.Builder
+	)
+
+	// Alternate maps and arrays, e.g. {"k0": [{"k2": [...]}]}.
+	for i := 0; i < depth; i++ {
+		if i%2 == 0 {
+			fmt.Fprintf(&in, `{"k%d": `, i)
+
+			if i > 0 {
+				key.WriteString(".")
+			}
+
+			fmt.Fprintf(&key, "k%d", i)
+		} else {
+			in.WriteString("[")
+			key.WriteString("[0]")
+		}
+	}
+
+	in.WriteString("1")
+
+	for i := depth - 1; i >= 0; i-- {
+		if i%2 == 0 {
+			// A sibling after each nested value checks the path is unwound.
+			fmt.Fprintf(&in, `, "s%d": 2}`, i)
+		} else {
+			in.WriteString("]")
+		}
+	}
+
+	pairs, err := Parse(strings.NewReader(in.String()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != 1+depth/2 {
+		t.Fatalf("expected %d pairs, got %d", 1+depth/2, len(pairs))
+	}
+
+	if pairs[0].Key != key.String() {
+		t.Errorf("expected %s, got %s", key.String(), pairs[0].Key)
+	}
+
+	if last := pairs[len(pairs)-1]; last.Key != "s0" {
+		t.Errorf("expected s0, got %s", last.Key)
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	a := bytes.NewBuffer(nil)
+	b := bytes.NewBuffer(nil)
+
+	enc := NewEncoder(a, WithSeparator("/"))
+
+	if err := enc.EncodeMap(map[string]interface{}{"x": map[string]int{"y": 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	enc.Reset(b)
+
+	if err := enc.EncodeMap(map[string]interface{}{"z": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(a.String()); s != `{"x/y":1}` {
+		t.Errorf("unexpected output %s", s)
+	}
+
+	if s := strings.TrimSpace(b.String()); s != `{"z":2}` {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestBytesWritten(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	n, err := enc.ConvertMapN(strings.NewReader(`{"a": {"b": 1}}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(buf.Len()) {
+		t.Errorf("expected %d bytes, got %d", buf.Len(), n)
+	}
+
+	m, err := enc.EncodeArrayN([]int{1, 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n+m != int64(buf.Len()) {
+		t.Errorf("expected %d bytes, got %d", int64(buf.Len())-n, m)
+	}
+
+	// The counter is removed once done.
+	if enc.w != buf {
+		t.Error("expected the writer to be restored")
+	}
+}
+
+func TestJQPaths(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"address": {"city": "Boresville"}, "hobbies": ["tennis"]}`, nil, `[[".address.city","Boresville"],[".hobbies[0]","tennis"]]`},
+		{`{"weird key": {"a.b": 1, "_ok2": 2, "2x": 3}}`, nil, `[[".[\"weird key\"][\"a.b\"]",1],[".[\"weird key\"]._ok2",2],[".[\"weird key\"][\"2x\"]",3]]`},
+		{`[[1], 2]`, []Option{WithArrayNotation(PlainNotation)}, `[[".[0][0]",1],[".[1]",2]]`},
+		{`[1]`, []Option{WithPrefix(".data")}, `[[".data[0]",1]]`},
+		{`{"a": 1}`, []Option{WithPrefix(".data")}, `[[".data.a",1]]`},
+		{`42`, nil, `[[".",42]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithJQPaths(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"address": {"city": "Boresville"}, "hobbies": ["tennis"]}`, nil, `[["$.address.city","Boresville"],["$.hobbies[0]","tennis"]]`},
+		{`{"weird key": {"it's": 1, "a\\b": 2}}`, nil, `[["$['weird key']['it\\'s']",1],["$['weird key']['a\\\\b']",2]]`},
+		{`[[1], 2]`, []Option{WithArrayNotation(PlainNotation)}, `[["$[0][0]",1],["$[1]",2]]`},
+		{`{"a": 1}`, []Option{WithPrefix("$.data")}, `[["$.data.a",1]]`},
+		{`{"a": 1}`, []Option{WithJQPaths(true)}, `[["$.a",1]]`},
+		{`42`, nil, `[["$",42]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithJSONPath(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestJSONPointer(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"address": {"city": "Boresville"}, "hobbies": ["tennis"]}`, nil, `[["/address/city","Boresville"],["/hobbies/0","tennis"]]`},
+		{`{"a/b": {"m~n": 1, "~1": 2, "/~": 3, "": 4}}`, nil, `[["/a~1b/m~0n",1],["/a~1b/~01",2],["/a~1b/~1~0",3],["/a~1b/",4]]`},
+		{`{"a.b": {"[0]": 1, "c\\d": 2}}`, nil, `[["/a.b/[0]",1],["/a.b/c\\d",2]]`},
+		{`[[1], 2]`, []Option{WithArrayNotation(PlainNotation), WithIndexWidth(3)}, `[["/0/0",1],["/1",2]]`},
+		{`{"a": 1}`, []Option{WithPrefix("/data")}, `[["/data/a",1]]`},
+		{`{"a": 1}`, []Option{WithJSONPath(true)}, `[["/a",1]]`},
+		{`42`, nil, `[["",42]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithJSONPointer(true))...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
 		}
-	`
+	}
+
+	// Pointers are read back by Decode.
+	input := `{"a/b": {"m~n": [1, {"~1": true}]}, "": null}`
+
+	flat := bytes.NewBuffer(nil)
+
+	if err := NewEncoder(flat, WithJSONPointer(true)).ConvertMap(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	if err := NewEncoder(buf, WithJSONPointer(true)).Decode(flat); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"":null,"a/b":{"m~n":[1,{"~1":true}]}}`
+
+	if s := strings.TrimSpace(buf.String()); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+func BenchmarkEncodeMap(b *testing.B) {
+	v := map[string]interface{}{
+		"name": "Bob Smith",
+		"address": map[string]interface{}{
+			"street":  "123 Main Street",
+			"city":    "Boresville",
+			"zipcode": 13943,
+		},
+		"hobbies": []string{"tennis", "coding", "cooking"},
+	}
+
+	enc := NewEncoder(io.Discard)
+
+	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
-		r := strings.NewReader(json)
-		Parse(r)
+		enc.EncodeMap(v)
 	}
 }
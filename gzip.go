@@ -0,0 +1,38 @@
+package flatjson
+
+import (
+	"compress/gzip"
+	"io"
+	"path/filepath"
+)
+
+// ConvertMapGzip re-encodes a gzip-compressed JSON value into a flat map.
+func (f *Encoder) ConvertMapGzip(r io.Reader) error {
+	return ConvertGzip(r, f.ConvertMap)
+}
+
+// ConvertArrayGzip re-encodes a gzip-compressed JSON value into a flat
+// array.
+func (f *Encoder) ConvertArrayGzip(r io.Reader) error {
+	return ConvertGzip(r, f.ConvertArray)
+}
+
+// ConvertGzip calls convert with the decompressed contents of r, so any
+// conversion can read gzip-compressed input.
+func ConvertGzip(r io.Reader, convert func(io.Reader) error) error {
+	zr, err := gzip.NewReader(r)
+
+	if err != nil {
+		return err
+	}
+
+	defer zr.Close()
+
+	return convert(zr)
+}
+
+// IsGzipPath returns true if the path has a .gz extension, which marks the
+// files ConvertFile decompresses.
+func IsGzipPath(path string) bool {
+	return filepath.Ext(path) == ".gz"
+}
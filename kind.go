@@ -0,0 +1,131 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Kind is the JSON type of a value.
+type Kind int
+
+// JSON types.
+const (
+	NullKind Kind = iota
+	BoolKind
+	NumberKind
+	StringKind
+	ObjectKind
+	ArrayKind
+)
+
+var kindNames = map[Kind]string{
+	NullKind:   "null",
+	BoolKind:   "boolean",
+	NumberKind: "number",
+	StringKind: "string",
+	ObjectKind: "object",
+	ArrayKind:  "array",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// kindOf returns the JSON type a value is encoded as.
+func kindOf(v interface{}) Kind {
+	switch v.(type) {
+	case nil:
+		return NullKind
+	case bool:
+		return BoolKind
+	case json.Number, float64:
+		return NumberKind
+	case string:
+		return StringKind
+	case map[string]interface{}:
+		return ObjectKind
+	case []interface{}:
+		return ArrayKind
+	}
+
+	// Values not produced by decoding, such as those from a value function.
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool:
+		return BoolKind
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return NumberKind
+	case reflect.String:
+		return StringKind
+	case reflect.Map, reflect.Struct:
+		return ObjectKind
+	case reflect.Slice, reflect.Array:
+		return ArrayKind
+	}
+
+	return NullKind
+}
+
+// tokenKind returns the JSON type of the value a token starts.
+func tokenKind(tok json.Token) Kind {
+	switch tok {
+	case lbrace:
+		return ObjectKind
+	case lsquare:
+		return ArrayKind
+	}
+
+	return kindOf(tok)
+}
+
+// Kind returns the JSON type of the pair's value.
+func (p *Pair) Kind() Kind {
+	return kindOf(p.Value)
+}
+
+// IsNull returns true if the value is null.
+func (p *Pair) IsNull() bool {
+	return p.Kind() == NullKind
+}
+
+// IsString returns true if the value is a string.
+func (p *Pair) IsString() bool {
+	return p.Kind() == StringKind
+}
+
+// StringValue returns the value if it is a string.
+func (p *Pair) StringValue() (string, bool) {
+	s, ok := p.Value.(string)
+	return s, ok
+}
+
+// Bool returns the value if it is a boolean.
+func (p *Pair) Bool() (bool, bool) {
+	b, ok := p.Value.(bool)
+	return b, ok
+}
+
+// Number returns the value if it is a number.
+func (p *Pair) Number() (json.Number, bool) {
+	if n, ok := p.Value.(json.Number); ok {
+		return n, true
+	}
+
+	if p.Kind() != NumberKind {
+		return "", false
+	}
+
+	b, err := json.Marshal(p.Value)
+
+	if err != nil {
+		return "", false
+	}
+
+	return json.Number(b), true
+}
@@ -0,0 +1,61 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPairKind(t *testing.T) {
+	r := strings.NewReader(`{"s": "x", "n": 1.5, "b": true, "z": null, "o": {}, "a": []}`)
+
+	pairs, err := Parse(r, WithEmptyCollections(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Kind{StringKind, NumberKind, BoolKind, NullKind, ObjectKind, ArrayKind}
+
+	for i, p := range pairs {
+		if p.Kind() != expected[i] {
+			t.Errorf("%s: expected %s, got %s", p.Key, expected[i], p.Kind())
+		}
+	}
+
+	if s, ok := pairs[0].StringValue(); !ok || s != "x" || !pairs[0].IsString() {
+		t.Errorf("expected string x, got %v", pairs[0].Value)
+	}
+
+	if n, ok := pairs[1].Number(); !ok || n != "1.5" {
+		t.Errorf("expected number 1.5, got %v", pairs[1].Value)
+	}
+
+	if b, ok := pairs[2].Bool(); !ok || !b {
+		t.Errorf("expected true, got %v", pairs[2].Value)
+	}
+
+	if !pairs[3].IsNull() {
+		t.Errorf("expected null, got %v", pairs[3].Value)
+	}
+
+	if _, ok := pairs[0].Number(); ok {
+		t.Error("string value should not be a number")
+	}
+
+	p := &Pair{Key: "i", Value: 42}
+
+	if n, ok := p.Number(); !ok || n != json.Number("42") {
+		t.Errorf("expected number 42, got %v", n)
+	}
+}
+
+func TestKindString(t *testing.T) {
+	if s := ObjectKind.String(); s != "object" {
+		t.Errorf("expected object, got %s", s)
+	}
+
+	if s := Kind(9).String(); s != "Kind(9)" {
+		t.Errorf("expected Kind(9), got %s", s)
+	}
+}
@@ -0,0 +1,118 @@
+package flatjson
+
+import "fmt"
+
+// ConflictPolicy determines how pairs with the same key are merged.
+type ConflictPolicy int
+
+const (
+	// ConflictError returns an error if two pairs have the same key.
+	ConflictError ConflictPolicy = iota
+
+	// FirstWins keeps the first pair with a key and drops the others.
+	FirstWins
+
+	// LastWins keeps the value of the last pair with a key, in the position
+	// of the first.
+	LastWins
+)
+
+var conflictPolicyNames = map[ConflictPolicy]string{
+	ConflictError: "error",
+	FirstWins:     "first",
+	LastWins:      "last",
+}
+
+func (c ConflictPolicy) String() string {
+	if name, ok := conflictPolicyNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("ConflictPolicy(%d)", int(c))
+}
+
+// MergeOptions configures how sets of pairs are merged. The zero value
+// returns an error on conflicting keys and leaves keys unchanged.
+type MergeOptions struct {
+	// How pairs with the same key are handled.
+	Conflict ConflictPolicy
+
+	// Prefixes of the keys of the set at the same position, prepended as
+	// WithPrefix does. Sets without a prefix, or with an empty one, are
+	// left unchanged.
+	Prefixes []string
+
+	// Options the sets were parsed with that format keys, such as
+	// WithSeparator and WithArrayNotation, so prefixes are joined to keys
+	// the same way.
+	Options []Option
+}
+
+// Merge concatenates sets of pairs, such as those returned by Parse for
+// several documents, into a single set. Pairs are prefixed and conflicting
+// keys handled as configured. The pairs in the sets are not modified.
+func (m MergeOptions) Merge(sets ...[]*Pair) ([]*Pair, error) {
+	if _, ok := conflictPolicyNames[m.Conflict]; !ok {
+		return nil, fmt.Errorf("flatjson: unknown conflict policy %s", m.Conflict)
+	}
+
+	o := newOptions(m.Options)
+
+	n := 0
+
+	for _, set := range sets {
+		n += len(set)
+	}
+
+	var (
+		pairs = make([]*Pair, 0, n)
+		index = make(map[string]int, n)
+	)
+
+	for i, set := range sets {
+		o.prefix = ""
+
+		if i < len(m.Prefixes) {
+			o.prefix = m.Prefixes[i]
+		}
+
+		for _, p := range set {
+			if o.prefix != "" {
+				q := *p
+				q.Key = o.prefixKey(p.Key)
+
+				if p.segs != nil {
+					q.segs = append([]interface{}{o.prefix}, p.segs...)
+				}
+
+				p = &q
+			}
+
+			j, ok := index[p.Key]
+
+			if !ok {
+				index[p.Key] = len(pairs)
+				pairs = append(pairs, p)
+				continue
+			}
+
+			switch m.Conflict {
+			case ConflictError:
+				return nil, fmt.Errorf("flatjson: duplicate key %q", p.Key)
+			case LastWins:
+				pairs[j] = p
+			}
+		}
+	}
+
+	return pairs, nil
+}
+
+// Merge concatenates sets of pairs, such as those returned by Parse for
+// several documents, into a single set. An error is returned if two pairs
+// have the same key, so no value is silently dropped when the result is
+// encoded as a map. Parse each document with WithPrefix to keep their keys
+// apart, or use MergeOptions to prefix the sets or resolve conflicts.
+func Merge(sets ...[]*Pair) ([]*Pair, error) {
+	return MergeOptions{}.Merge(sets...)
+}
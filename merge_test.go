@@ -0,0 +1,104 @@
+package flatjson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	a, err := Parse(strings.NewReader(`{"name": "Bob"}`), WithPrefix("a"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Parse(strings.NewReader(`{"name": "Alice", "age": 30}`), WithPrefix("b"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := Merge(a, b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+
+	for _, p := range pairs {
+		keys = append(keys, p.Key)
+	}
+
+	if s := strings.Join(keys, ","); s != "a.name,b.name,b.age" {
+		t.Errorf("unexpected keys %s", s)
+	}
+
+	if _, err := Merge(a, a); err == nil {
+		t.Error("expected duplicate key error")
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	a, err := Parse(strings.NewReader(`{"name": "Bob", "age": 30}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Parse(strings.NewReader(`{"name": "Alice", "tags": ["x"]}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Parse(strings.NewReader(`[1]`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name     string
+		Options  MergeOptions
+		Expected string
+	}{
+		{"first wins", MergeOptions{Conflict: FirstWins}, `"name": "Bob","age": 30,"tags[0]": "x","[0]": 1`},
+		{"last wins", MergeOptions{Conflict: LastWins}, `"name": "Alice","age": 30,"tags[0]": "x","[0]": 1`},
+		{"prefixes", MergeOptions{Prefixes: []string{"a", "b", "c"}}, `"a.name": "Bob","a.age": 30,"b.name": "Alice","b.tags[0]": "x","c[0]": 1`},
+		{"partial prefixes", MergeOptions{Prefixes: []string{"a"}}, `"a.name": "Bob","a.age": 30,"name": "Alice","tags[0]": "x","[0]": 1`},
+		{"separator", MergeOptions{Prefixes: []string{"a", "b", "c"}, Options: []Option{WithSeparator("/")}}, `"a/name": "Bob","a/age": 30,"b/name": "Alice","b/tags[0]": "x","c[0]": 1`},
+	}
+
+	for _, test := range tests {
+		pairs, err := test.Options.Merge(a, b, c)
+
+		if err != nil {
+			t.Errorf("%s: %s", test.Name, err)
+			continue
+		}
+
+		var s []string
+
+		for _, p := range pairs {
+			s = append(s, p.String())
+		}
+
+		if actual := strings.Join(s, ","); actual != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, actual)
+		}
+	}
+
+	// The pairs of the sets are not modified.
+	if a[0].Key != "name" || b[0].Value != "Alice" {
+		t.Errorf("expected sets to be unchanged, got %s and %s", a[0], b[0])
+	}
+
+	if _, err := (MergeOptions{Conflict: ConflictPolicy(9)}).Merge(a); err == nil || err.Error() != "flatjson: unknown conflict policy ConflictPolicy(9)" {
+		t.Errorf("expected unknown conflict policy error, got %v", err)
+	}
+
+	if s := LastWins.String(); s != "last" {
+		t.Errorf("expected last, got %s", s)
+	}
+}
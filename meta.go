@@ -0,0 +1,122 @@
+package flatjson
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// defaultMetaKey is the key of the header Decode recognizes if none is set
+// by WithMetaHeader.
+const defaultMetaKey = "_meta"
+
+// meta describes how keys were flattened, so they can be unflattened
+// without knowing the options.
+type meta struct {
+	Sep           string        `json:"sep"`
+	ArrayNotation ArrayNotation `json:"arrayNotation"`
+	KeyEscaping   bool          `json:"keyEscaping"`
+}
+
+// metaHeader wraps output in an object with the meta header under the key
+// and the output under "data".
+type metaHeader struct {
+	key  string
+	meta meta
+	data interface{}
+}
+
+func (h metaHeader) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+
+	// Fields are written in order so the header comes first.
+	buf.WriteByte('{')
+
+	for i, v := range []interface{}{h.key, h.meta, "data", h.data} {
+		switch i {
+		case 1, 3:
+			buf.WriteByte(':')
+		case 2:
+			buf.WriteByte(',')
+		}
+
+		if err := encodeTo(buf, enc, v); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// WithMetaHeader sets a key under which map and array output is preceded
+// by a header describing the separator, array notation and key escaping,
+// e.g. {"_meta": {"sep": ".", ...}, "data": {...}}, so Decode can
+// unflatten the output without being configured the same way. The output
+// itself is under "data". An empty key, the default, writes no header.
+func WithMetaHeader(key string) Option {
+	return func(o *options) {
+		o.metaKey = key
+	}
+}
+
+// withMeta returns the output wrapped with the meta header, if it is set.
+func (o *options) withMeta(v interface{}) interface{} {
+	if o.metaKey == "" {
+		return v
+	}
+
+	return metaHeader{
+		key: o.metaKey,
+		meta: meta{
+			Sep:           o.sep,
+			ArrayNotation: o.notation,
+			KeyEscaping:   o.escape,
+		},
+		data: v,
+	}
+}
+
+// readMeta returns the data of a flat map wrapped with a meta header and
+// the options configured by the header. The header is expected under the
+// key set by WithMetaHeader, or "_meta" if none is set. If the map does
+// not have a header, it is returned with the options unchanged.
+func readMeta(flat map[string]interface{}, o options) (map[string]interface{}, options) {
+	key := o.metaKey
+
+	if key == "" {
+		key = defaultMetaKey
+	}
+
+	if len(flat) != 2 {
+		return flat, o
+	}
+
+	h, ok := flat[key].(map[string]interface{})
+	data, isMap := flat["data"].(map[string]interface{})
+
+	if !ok || !isMap {
+		return flat, o
+	}
+
+	sep, ok := h["sep"].(string)
+
+	if !ok || sep == "" {
+		return flat, o
+	}
+
+	o.sep = sep
+
+	if s, ok := h["arrayNotation"].(string); ok {
+		if n, err := ParseArrayNotation(s); err == nil {
+			o.notation = n
+		}
+	}
+
+	if escape, ok := h["keyEscaping"].(bool); ok {
+		o.escape = escape
+	}
+
+	return data, o
+}
@@ -0,0 +1,96 @@
+package flatjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMetaHeader(t *testing.T) {
+	input := `{"a": {"b.c": [1, 2]}}`
+
+	tests := []struct {
+		Key      string
+		Options  []Option
+		Expected string
+	}{
+		{
+			"_meta",
+			nil,
+			`{"_meta":{"sep":".","arrayNotation":"bracket","keyEscaping":true},"data":{"a.b\\.c[0]":1,"a.b\\.c[1]":2}}`,
+		},
+		{
+			"info",
+			[]Option{WithSeparator("/"), WithArrayNotation(PlainNotation)},
+			`{"info":{"sep":"/","arrayNotation":"plain","keyEscaping":true},"data":{"a/b.c/0":1,"a/b.c/1":2}}`,
+		},
+		{
+			"_meta",
+			[]Option{WithKeyEscaping(false), WithSeparator(":"), WithPreserveOrder(true)},
+			`{"_meta":{"sep":":","arrayNotation":"bracket","keyEscaping":false},"data":{"a:b.c[0]":1,"a:b.c[1]":2}}`,
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append(test.Options, WithMetaHeader(test.Key))...)
+
+		if err := enc.ConvertMap(strings.NewReader(input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("expected %s, got %s", test.Expected, s)
+		}
+
+		// The header configures unflattening, even with different options.
+		out := bytes.NewBuffer(nil)
+
+		// Keys other than "_meta" must be set to be recognized.
+		dec := NewEncoder(out, WithSeparator("|"), WithMetaHeader(test.Key))
+
+		if err := dec.Decode(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(out.String()); s != `{"a":{"b.c":[1,2]}}` {
+			t.Errorf("expected the input, got %s", s)
+		}
+	}
+
+	// Array output is wrapped as well.
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithMetaHeader("_meta"))
+
+	if err := enc.ConvertArray(strings.NewReader(`[true]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"_meta":{"sep":".","arrayNotation":"bracket","keyEscaping":true},"data":[["[0]",true]]}`
+
+	if s := strings.TrimSpace(buf.String()); s != exp {
+		t.Errorf("expected %s, got %s", exp, s)
+	}
+
+	// Unflatten recognizes the default key.
+	b, err := Unflatten(strings.NewReader(`{"_meta": {"sep": "/"}, "data": {"a/b": 1}}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"a":{"b":1}}` {
+		t.Errorf("expected the header to be read, got %s", s)
+	}
+
+	// Maps that only look like a header are unflattened as is.
+	b, err = Unflatten(strings.NewReader(`{"_meta": {"x": 1}, "data": {"a/b": 1}}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != `{"_meta":{"x":1},"data":{"a/b":1}}` {
+		t.Errorf("expected no header, got %s", s)
+	}
+}
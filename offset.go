@@ -0,0 +1,44 @@
+package flatjson
+
+import (
+	"io"
+)
+
+// recorder keeps the input read by a decoder that has not yet been
+// tokenized, so the start of each token can be found. The decoder only
+// reports the offset after a token, which follows any whitespace and
+// separators before it.
+type recorder struct {
+	r io.Reader
+
+	// Input from the offset base onward.
+	buf  []byte
+	base int64
+}
+
+func (r *recorder) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.buf = append(r.buf, b[:n]...)
+	return n, err
+}
+
+// start returns the offset of the token between the decoder offsets before
+// and after it was read, and discards the input up to the end of it.
+func (r *recorder) start(prev, end int64) int64 {
+	off := prev
+
+	for _, c := range r.buf[prev-r.base : end-r.base] {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != ',' && c != ':' {
+			break
+		}
+
+		off++
+	}
+
+	// Copy the remaining input down so the buffer does not grow with it.
+	n := copy(r.buf, r.buf[end-r.base:])
+	r.buf = r.buf[:n]
+	r.base = end
+
+	return off
+}
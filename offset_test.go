@@ -0,0 +1,90 @@
+package flatjson
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestOffsets(t *testing.T) {
+	input := `{"name": "Bob", "tags": ["a\"b", 2, {}], "e": [],` + "\n" + `"x": {"y": null}}  3`
+
+	tests := []struct {
+		Options  []Option
+		Expected map[string]int64
+	}{
+		{
+			Expected: map[string]int64{
+				"name":    9,
+				"tags[0]": 25,
+				"tags[1]": 33,
+				"tags[2]": 36,
+				"e":       46,
+				"x.y":     61,
+				"":        69,
+			},
+		},
+		{
+			Options: []Option{WithIncludeContainers(true)},
+			Expected: map[string]int64{
+				"name":    9,
+				"tags":    24,
+				"tags[0]": 25,
+				"tags[1]": 33,
+				"tags[2]": 36,
+				"e":       46,
+				"x":       55,
+				"x.y":     61,
+				"":        69,
+			},
+		},
+		{
+			Options: []Option{WithMaxDepth(1)},
+			Expected: map[string]int64{
+				"name": 9,
+				"tags": 24,
+				"e":    46,
+				"x":    55,
+				"":     69,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		opts := append(test.Options, WithOffsets(true))
+
+		// Read a byte at a time so tokens span reads.
+		dec := NewDecoder(iotest.OneByteReader(strings.NewReader(input)), opts...)
+
+		n := 0
+
+		for pair, err := range dec.All() {
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n++
+
+			if off, ok := test.Expected[pair.Key]; !ok || pair.Offset != off {
+				t.Errorf("%s: expected offset %d, got %d", pair.Key, off, pair.Offset)
+			}
+		}
+
+		if n != len(test.Expected) {
+			t.Errorf("expected %d pairs, got %d", len(test.Expected), n)
+		}
+	}
+
+	// Offsets are zero unless enabled.
+	pairs, err := Parse(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range pairs {
+		if pair.Offset != 0 {
+			t.Errorf("%s: expected no offset, got %d", pair.Key, pair.Offset)
+		}
+	}
+}
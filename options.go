@@ -0,0 +1,1012 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Option configures how values are flattened.
+type Option func(*options)
+
+type options struct {
+	// Separator placed between path segments.
+	sep string
+
+	// Format of array indices in keys.
+	notation ArrayNotation
+
+	// Minimum number of digits of array indices.
+	indexWidth int
+
+	// Keep empty maps and arrays rather than collapsing them.
+	keepEmpty bool
+
+	// Value empty maps and arrays are collapsed to.
+	emptyValue interface{}
+
+	// Depth at which values are no longer flattened.
+	maxDepth int
+
+	// Return an error rather than dropping values with duplicate keys.
+	strictKeys bool
+
+	// Suffix duplicate keys rather than dropping their values.
+	dedupeKeys bool
+
+	// Escape separators, brackets and backslashes in literal keys.
+	escape bool
+
+	// Expand arrays into indexed keys.
+	flattenArrays bool
+
+	// Sort pairs by key before encoding.
+	sortKeys bool
+
+	// Encode map output in the order of the pairs.
+	preserveOrder bool
+
+	// Indentation of the encoded output.
+	indent string
+
+	// Terminator written after each encoded value, if not a newline.
+	newline string
+
+	// Key the output is nested under.
+	root string
+
+	// Prefix prepended to every key.
+	prefix string
+
+	// Pattern keys must match to be emitted.
+	pattern string
+	filter  *keyFilter
+
+	// Transforms each key.
+	keyFunc func(key string) string
+
+	// Transforms each value.
+	valueFunc func(key string, value interface{}) interface{}
+
+	// Syntax keys are formatted in.
+	syntax pathSyntax
+
+	// Emit pairs for maps and arrays as well as their contents.
+	containers bool
+
+	// Drop pairs with null values.
+	skipNulls bool
+
+	// Trim whitespace around string values.
+	trimSpace bool
+
+	// Write numbers without exponents.
+	plainNumbers bool
+
+	// Reject keys containing control characters.
+	validateKeys bool
+
+	// Reject keys that are not FieldMask paths.
+	fieldMask bool
+
+	// Maximum length of keys in runes and the function that shortens
+	// longer keys.
+	maxKeyLen  int
+	shortenKey func(key string, n int) string
+
+	// Prefix keys with their nesting depth.
+	depthPrefix bool
+
+	// Ignore comments in the input.
+	allowComments bool
+
+	// Limits on the number of pairs and bytes of input.
+	maxPairs      int
+	maxInputBytes int64
+
+	// Encode keys in array output as arrays of path segments.
+	pathSegments bool
+
+	// Encode array output as objects with the given field names.
+	pairObjects bool
+	keyField    string
+	valueField  string
+
+	// Record the input offset of each value.
+	offsets bool
+
+	// Encode values along with their JSON types.
+	typedValues bool
+
+	// Encode nulls, booleans and numbers as strings.
+	stringValues bool
+
+	// Key of the header describing how keys were flattened.
+	metaKey string
+
+	// Reject top-level values other than maps.
+	objectRoot bool
+
+	// Keep arrays as arrays of flattened elements.
+	arraysAsArrays bool
+
+	// Maximum number of elements flattened per array.
+	maxArrayLen int
+
+	// Replace array indices with a wildcard.
+	wildcard bool
+
+	// Flatten arrays of one element as the element.
+	unwrapSingle bool
+
+	// Collect the values of duplicate map keys into arrays.
+	duplicateKeys bool
+
+	// Accumulates statistics of flattened values, set by an Encoder.
+	stats *stats
+}
+
+// newOptions returns the default options with opts applied.
+func newOptions(opts []Option) options {
+	o := options{
+		sep:           pathd,
+		escape:        true,
+		flattenArrays: true,
+		shortenKey:    TruncateKey,
+		keyField:      "key",
+		valueField:    "value",
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Field mask paths are always joined by dots.
+	if o.fieldMask {
+		o.sep = pathd
+	}
+
+	// Compile the filter now that the separator is known.
+	if o.pattern != "" {
+		o.filter = newKeyFilter(o.pattern, &o)
+	}
+
+	return o
+}
+
+// WithSeparator sets the string placed between path segments. The default
+// is ".". An empty separator selects the default.
+func WithSeparator(sep string) Option {
+	return func(o *options) {
+		if sep == "" {
+			sep = pathd
+		}
+
+		o.sep = sep
+	}
+}
+
+// WithEmptyCollections sets whether empty maps and arrays are kept as {}
+// and [] values. By default they are collapsed to null. Either way a nested
+// empty map or array produces exactly one pair keyed by its path, while an
+// empty top-level value produces no pairs.
+func WithEmptyCollections(keep bool) Option {
+	return func(o *options) {
+		o.keepEmpty = keep
+	}
+}
+
+// WithIncludeContainers sets whether nested maps and arrays are emitted as
+// pairs of their own, before their contents, with the value "object" or
+// "array". For example, {"a": {"b": 1}} is flattened to the pairs a and
+// a.b. An empty map or array is only emitted as a container, and maps and
+// arrays kept whole by WithMaxDepth or WithFlattenArrays are emitted as
+// values as usual.
+func WithIncludeContainers(include bool) Option {
+	return func(o *options) {
+		o.containers = include
+	}
+}
+
+// WithSkipNulls sets whether pairs with null values are dropped. This
+// includes empty maps and arrays collapsed to null, but not those kept by
+// WithEmptyCollections. Values are checked after WithValueFunc, so a value
+// function can also drop pairs by returning nil.
+func WithSkipNulls(skip bool) Option {
+	return func(o *options) {
+		o.skipNulls = skip
+	}
+}
+
+// WithTrimSpace sets whether leading and trailing whitespace is trimmed
+// from string values. Other values are unchanged. Values are trimmed before
+// WithValueFunc is called.
+func WithTrimSpace(trim bool) Option {
+	return func(o *options) {
+		o.trimSpace = trim
+	}
+}
+
+// WithNoScientificNotation sets whether numbers are written without an
+// exponent, e.g. 1e20 as 100000000000000000000 and 1.5e-3 as 0.0015. This
+// applies to numbers in the input, which are otherwise written as they
+// appear, and to floats of encoded values, which use exponents from 1e21.
+// Digits are moved rather than parsed as a float, so no precision is lost.
+// Exponents beyond 1000 are kept. Values set by WithValueFunc are not
+// changed.
+func WithNoScientificNotation(plain bool) Option {
+	return func(o *options) {
+		o.plainNumbers = plain
+	}
+}
+
+// WithAllowComments sets whether // and /* */ comments are allowed in the
+// input, as in JSONC configuration files. Comments are ignored, while
+// slashes within strings are kept.
+func WithAllowComments(allow bool) Option {
+	return func(o *options) {
+		o.allowComments = allow
+	}
+}
+
+// WithOffsets sets whether the byte offset in the input where each value
+// starts is recorded in Pair.Offset. The offset of an empty map or array is
+// that of its opening delimiter, and a leading byte order mark is not
+// counted. Offsets are not recorded for values that are not read from JSON
+// input, such as those flattened by EncodeMap, EncodeArray or Flatten.
+func WithOffsets(offsets bool) Option {
+	return func(o *options) {
+		o.offsets = offsets
+	}
+}
+
+// WithTypedValues sets whether values are encoded along with their JSON
+// types, e.g. {"zipcode": {"type": "number", "value": 13943}}, so a number
+// is distinguished from a string of its digits. Types are those of Kind.
+// Values returned by Parse and Decoder are not changed.
+func WithTypedValues(typed bool) Option {
+	return func(o *options) {
+		o.typedValues = typed
+	}
+}
+
+// WithStringValues sets whether nulls, booleans and numbers are encoded as
+// strings, e.g. "13943" and "true", so every value has the same type. Null
+// becomes the empty string. Maps and arrays kept as values are unchanged,
+// and values returned by Parse and Decoder are not converted. With
+// WithTypedValues, the type is that of the original value.
+func WithStringValues(str bool) Option {
+	return func(o *options) {
+		o.stringValues = str
+	}
+}
+
+// WithRequireObjectRoot sets whether a top-level value other than a map,
+// such as an array, is rejected with an error rather than flattened to
+// keys like "[0]". Each value of a stream is checked.
+func WithRequireObjectRoot(require bool) Option {
+	return func(o *options) {
+		o.objectRoot = require
+	}
+}
+
+// WithMaxPairs limits the number of pairs an input may be flattened to.
+// Once more than n pairs are produced, flattening stops with an error
+// wrapping ErrLimitExceeded. Zero or less is no limit, which is the default.
+func WithMaxPairs(n int) Option {
+	return func(o *options) {
+		o.maxPairs = n
+	}
+}
+
+// truncatedKey is the key of the marker pair of a truncated array.
+const truncatedKey = "__truncated__"
+
+// WithMaxArrayExpansion limits the number of elements of each array that
+// are flattened, guarding against inputs with huge arrays. The elements
+// past the first n are skipped and the array is marked with a pair whose
+// key is the array's key followed by "__truncated__" as if it were a map
+// key, e.g. "a.__truncated__", and whose value is true. Arrays kept whole
+// are not limited. Zero or less is no limit, which is the default.
+func WithMaxArrayExpansion(n int) Option {
+	return func(o *options) {
+		o.maxArrayLen = n
+	}
+}
+
+// WithMaxInputBytes limits the number of bytes read from an input. Once
+// more than n bytes are read, flattening stops with an error wrapping
+// ErrLimitExceeded. Zero or less is no limit, which is the default. Values
+// passed to EncodeMap and EncodeArray are not limited.
+func WithMaxInputBytes(n int64) Option {
+	return func(o *options) {
+		o.maxInputBytes = n
+	}
+}
+
+// WithEmptyValue sets the value empty maps and arrays are collapsed to,
+// such as "" or a sentinel string. The default is nil, which is encoded as
+// null. The same value is used for every empty collection, so it should not
+// be modified. It has no effect if WithEmptyCollections is set.
+func WithEmptyValue(v interface{}) Option {
+	return func(o *options) {
+		o.emptyValue = v
+	}
+}
+
+// WithMaxDepth limits flattening to n levels of nesting. Maps and arrays
+// below that depth are kept whole as the value of their key. A depth of
+// zero or less flattens all levels, which is the default.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithFlattenArrays sets whether arrays are expanded into indexed keys.
+// If false, arrays are kept whole as the value of their key while maps
+// are still flattened. A top-level array is always expanded. The default
+// is true.
+func WithFlattenArrays(flatten bool) Option {
+	return func(o *options) {
+		o.flattenArrays = flatten
+	}
+}
+
+// WithArraysAsArrays sets whether nested arrays are kept as arrays with
+// each element flattened on its own, e.g. {"items": [{"a": {"b": 1}}]}
+// becomes {"items": [{"a.b": 1}]}. Maps within arrays become flat maps,
+// arrays within arrays are handled the same way, and other elements are
+// unchanged. Elements are flattened with the same options except for the
+// prefix and key filter, which only apply to the enclosing keys. Like
+// WithFlattenArrays, a top-level array is always expanded.
+func WithArraysAsArrays(arrays bool) Option {
+	return func(o *options) {
+		o.arraysAsArrays = arrays
+	}
+}
+
+// flattenElements flattens the maps in the array, and those in nested
+// arrays, into flat maps. The array is modified in place.
+func (o *options) flattenElements(a []interface{}) ([]interface{}, error) {
+	eo := *o
+	eo.prefix, eo.pattern, eo.filter = "", "", nil
+
+	// The elements are counted as part of their array's value.
+	eo.stats = nil
+
+	for i, v := range a {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			pairs, err := parseValueOf(v, &eo)
+
+			if err != nil {
+				return nil, err
+			}
+
+			a[i] = mapPairs(pairs).toMap()
+
+		case []interface{}:
+			elems, err := eo.flattenElements(v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			a[i] = elems
+		}
+	}
+
+	return a, nil
+}
+
+// WithSortKeys sets whether pairs are sorted by key before being encoded,
+// so array output does not depend on the order of the input. Map output is
+// sorted by key unless WithPreserveOrder is set.
+func WithSortKeys(sort bool) Option {
+	return func(o *options) {
+		o.sortKeys = sort
+	}
+}
+
+// WithPairObjects sets whether array output encodes each pair as an object,
+// e.g. {"key": "name", "value": "Bob"}, rather than a two-element array.
+// Field names may be given for the key and value, which default to "key"
+// and "value". They are also used by ConvertPairsLines.
+func WithPairObjects(enable bool, fields ...string) Option {
+	return func(o *options) {
+		o.pairObjects = enable
+
+		if len(fields) > 0 && fields[0] != "" {
+			o.keyField = fields[0]
+		}
+
+		if len(fields) > 1 && fields[1] != "" {
+			o.valueField = fields[1]
+		}
+	}
+}
+
+// WithPathSegments sets whether array output encodes each key as an array
+// of its path segments, with literal map keys as strings and array indices
+// as integers, e.g. [["hobbies", 0], "tennis"]. A prefix is the first
+// segment. Segments are built from the input, so they are not changed by
+// WithKeyFunc, and a top-level scalar has no segments.
+func WithPathSegments(segments bool) Option {
+	return func(o *options) {
+		o.pathSegments = segments
+	}
+}
+
+// pairObject returns the pair encoded as an object.
+func (o *options) pairObject(p *Pair) pairObject {
+	return pairObject{
+		pair:  p,
+		key:   o.keyField,
+		value: o.valueField,
+		segs:  o.pathSegments,
+	}
+}
+
+// WithPreserveOrder sets whether map output keeps the keys in the order
+// they appear in the input rather than sorting them. If a key appears more
+// than once, its last value is written where it first appears.
+func WithPreserveOrder(preserve bool) Option {
+	return func(o *options) {
+		o.preserveOrder = preserve
+	}
+}
+
+// WithIndent sets the string used to indent each level of the encoded
+// output. By default the output is compact.
+func WithIndent(indent string) Option {
+	return func(o *options) {
+		o.indent = indent
+	}
+}
+
+// WithLineTerminator sets the line terminator written after each map or
+// array, such as "\r\n". It ends every line of output from ConvertMapLines,
+// ConvertArrayLines and ConvertPairsLines. Line breaks within indented
+// output are not changed. An empty terminator selects the default, "\n".
+// Input may use either line ending.
+func WithLineTerminator(term string) Option {
+	return func(o *options) {
+		if term == "\n" {
+			term = ""
+		}
+
+		o.newline = term
+	}
+}
+
+// WithRoot sets a key the map or array output is nested under, e.g.
+// {"data": {"a.b": 1}} for the root "data". Unlike WithPrefix, keys are
+// unchanged. An empty name, the default, does not nest the output.
+func WithRoot(name string) Option {
+	return func(o *options) {
+		o.root = name
+	}
+}
+
+// WithPrefix sets a prefix that is prepended to every key followed by the
+// separator, e.g. the prefix "user" turns "name" into "user.name". The prefix
+// acts as the key of the top-level value, so a top-level array is indexed
+// as "user[0]".
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// prefixKey prepends the prefix to a key.
+func (o *options) prefixKey(k string) string {
+	switch o.syntax {
+	case jqSyntax:
+		// A jq path always starts with a dot, which alone is the path of
+		// the top-level value.
+		k = o.prefix + k
+
+		if k == "" || k[0] == '[' {
+			k = "." + k
+		}
+
+		return k
+
+	case jsonPathSyntax:
+		if o.prefix == "" {
+			return "$" + k
+		}
+
+		return o.prefix + k
+
+	case jsonPointerSyntax:
+		return o.prefix + k
+	}
+
+	if o.prefix == "" {
+		return k
+	}
+
+	if k == "" {
+		return o.prefix
+	}
+
+	// Index of a top-level array.
+	if o.notation == BracketNotation && k[0] == '[' {
+		return o.prefix + k
+	}
+
+	return o.prefix + o.sep + k
+}
+
+// WithDepthPrefix sets whether each key is prefixed with its nesting depth
+// and a colon, e.g. "2:address.city", which can help spot structural
+// anomalies. The prefix is added after WithKeyFunc is applied.
+func WithDepthPrefix(prefix bool) Option {
+	return func(o *options) {
+		o.depthPrefix = prefix
+	}
+}
+
+// WithKeyFilter only emits pairs whose key matches the pattern. Patterns
+// are matched segment by segment, where * matches any part of a single
+// segment and ** matches any number of segments. For example, "address.*"
+// matches the fields of address and "**.zipcode" matches zipcode at any
+// depth.
+func WithKeyFilter(pattern string) Option {
+	return func(o *options) {
+		o.pattern = pattern
+	}
+}
+
+// WithValidateKeys sets whether flattening stops with an error naming the
+// key if a key contains a NUL or other C0 control character, U+0000 to
+// U+001F, which can break downstream consumers. Keys are checked after
+// WithKeyFunc and WithMaxKeyLength are applied.
+func WithValidateKeys(validate bool) Option {
+	return func(o *options) {
+		o.validateKeys = validate
+	}
+}
+
+// WithKeyFunc sets a function that transforms each flattened key, such as
+// strings.ToLower. It is applied after WithKeyFilter and before
+// WithValueFunc, which receives the transformed key. Transforms can map
+// distinct keys to the same key, in which case map output keeps the last
+// value unless WithStrictKeys is set.
+func WithKeyFunc(fn func(key string) string) Option {
+	return func(o *options) {
+		o.keyFunc = fn
+	}
+}
+
+// WithMaxKeyLength limits keys to n runes. Longer keys are shortened with
+// TruncateKey unless another function is set with WithKeyShortener. It is
+// applied after WithKeyFunc. A length of zero or less does not limit keys,
+// which is the default.
+//
+// No mapping from shortened keys to the original keys is recorded. To keep
+// one, set a WithKeyShortener that records the keys it shortens.
+func WithMaxKeyLength(n int) Option {
+	return func(o *options) {
+		o.maxKeyLen = n
+	}
+}
+
+// WithKeyShortener sets the function used to shorten keys longer than the
+// length set by WithMaxKeyLength. It is called with the key and the maximum
+// length, and should return the same key for the same input. Wrap
+// TruncateKey to record how keys were shortened.
+func WithKeyShortener(fn func(key string, n int) string) Option {
+	return func(o *options) {
+		if fn == nil {
+			fn = TruncateKey
+		}
+
+		o.shortenKey = fn
+	}
+}
+
+// Length of the hash suffix added by TruncateKey, including the "~".
+const keyHashLen = 9
+
+// TruncateKey shortens a key to n runes by replacing its end with a "~"
+// and a hash of the full key, e.g. "address.street.na~1a2b3c4d", so keys
+// that share a prefix remain distinct. Keys of n runes or fewer, or any key
+// if n is negative, are returned unchanged.
+func TruncateKey(key string, n int) string {
+	if n < 0 || utf8.RuneCountInString(key) <= n {
+		return key
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	suffix := fmt.Sprintf("~%08x", h.Sum32())
+
+	if n <= keyHashLen {
+		return suffix[keyHashLen-n:]
+	}
+
+	// Keep the leading runes that fit before the suffix.
+	i, keep := 0, n-keyHashLen
+
+	for j := range key {
+		if keep == 0 {
+			i = j
+			break
+		}
+
+		keep--
+	}
+
+	return key[:i] + suffix
+}
+
+// WithValueFunc sets a function that is called with the key and value of
+// each pair before it is emitted. The value it returns replaces the
+// original, so returning the value unchanged passes it through.
+func WithValueFunc(fn func(key string, value interface{}) interface{}) Option {
+	return func(o *options) {
+		o.valueFunc = fn
+	}
+}
+
+// WithStrictKeys sets whether map output returns an error when two pairs
+// have the same key, such as the literal key "a.b" and the nested key
+// {"a": {"b": ...}} when key escaping is disabled. By default the last
+// value wins.
+func WithStrictKeys(strict bool) Option {
+	return func(o *options) {
+		o.strictKeys = strict
+	}
+}
+
+// WithDedupeKeys sets whether map output keeps every value when pairs have
+// the same key by suffixing the second and later occurrences with "#1",
+// "#2" and so on, e.g. "a.b" and "a.b#1". Suffixes already used by other
+// keys are skipped. It takes precedence over WithStrictKeys.
+func WithDedupeKeys(dedupe bool) Option {
+	return func(o *options) {
+		o.dedupeKeys = dedupe
+	}
+}
+
+// WithKeyEscaping sets whether separators, brackets and backslashes that
+// appear in literal keys are escaped with a backslash, so {"a.b": 1} is
+// flattened to the key `a\.b` and does not collide with {"a": {"b": 1}}.
+// With PlainNotation, numeric keys are also escaped, e.g. `a.\0`, so they
+// are not mistaken for array indices. Escaping is enabled by default.
+func WithKeyEscaping(escape bool) Option {
+	return func(o *options) {
+		o.escape = escape
+	}
+}
+
+// pathSyntax is a query syntax keys can be formatted in.
+type pathSyntax int
+
+const (
+	// Keys are joined with the separator.
+	defaultSyntax pathSyntax = iota
+
+	// Keys are jq paths.
+	jqSyntax
+
+	// Keys are JSONPath expressions.
+	jsonPathSyntax
+
+	// Keys are JSON Pointers.
+	jsonPointerSyntax
+)
+
+// WithJQPaths sets whether keys are formatted as jq paths, e.g.
+// ".address.city" and ".hobbies[0]", so they can be used as jq filters.
+// Keys that are not identifiers are quoted, e.g. `.["weird key"]`. This
+// overrides the separator, array notation and key escaping. A prefix set
+// with WithPrefix should itself be a jq path such as ".data".
+func WithJQPaths(jq bool) Option {
+	return func(o *options) {
+		o.setSyntax(jqSyntax, jq)
+	}
+}
+
+// WithJSONPath sets whether keys are formatted as JSONPath expressions,
+// e.g. "$.address.city" and "$.hobbies[0]". Keys that are not identifiers
+// are quoted, e.g. "$['weird key']". Like WithJQPaths, this overrides the
+// separator, array notation and key escaping, and a prefix should itself
+// be a path such as "$.data".
+func WithJSONPath(jsonPath bool) Option {
+	return func(o *options) {
+		o.setSyntax(jsonPathSyntax, jsonPath)
+	}
+}
+
+// WithJSONPointer sets whether keys are formatted as JSON Pointers as in
+// RFC 6901, e.g. "/address/city" and "/hobbies/0". A "~" in a literal key
+// is escaped as "~0" and a "/" as "~1", e.g. {"a/b": 1} is flattened to
+// "/a~1b". The top-level value has the empty pointer, "". Like WithJQPaths,
+// this overrides the separator, array notation and key escaping, and a
+// prefix should itself be a pointer such as "/data". Decode reads pointers
+// back, though numeric segments are taken to be array indices.
+func WithJSONPointer(pointer bool) Option {
+	return func(o *options) {
+		o.setSyntax(jsonPointerSyntax, pointer)
+	}
+}
+
+// pointerEscaper escapes a literal key as a JSON Pointer reference token.
+// Tildes are replaced first so the escape of a slash is not escaped again.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// setSyntax enables or disables the syntax. Disabling a syntax that is not
+// in use has no effect.
+func (o *options) setSyntax(syntax pathSyntax, enable bool) {
+	if enable {
+		o.syntax = syntax
+	} else if o.syntax == syntax {
+		o.syntax = defaultSyntax
+	}
+}
+
+// jqSegment returns the jq path segment for the literal key k.
+func jqSegment(k string) string {
+	if isIdentifier(k) {
+		return "." + k
+	}
+
+	// Keys are quoted as JSON strings, which jq accepts.
+	q, _ := marshalNoEscape(k)
+
+	return "[" + q + "]"
+}
+
+// jsonPathSegment returns the JSONPath segment for the literal key k.
+func jsonPathSegment(k string) string {
+	if isIdentifier(k) {
+		return "." + k
+	}
+
+	var b strings.Builder
+
+	// Quote with single quotes as in RFC 9535.
+	b.WriteString("['")
+
+	for _, r := range k {
+		switch r {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+
+	b.WriteString("']")
+
+	return b.String()
+}
+
+// isIdentifier returns true if s is an identifier that can be used
+// unquoted in jq and JSONPath.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// segment returns the path segment for the literal key k.
+func (o *options) segment(k string) string {
+	switch o.syntax {
+	case jqSyntax:
+		return jqSegment(k)
+	case jsonPathSyntax:
+		return jsonPathSegment(k)
+	case jsonPointerSyntax:
+		return "/" + pointerEscaper.Replace(k)
+	}
+
+	return o.escapeKey(k)
+}
+
+// escapeKey escapes a literal key for use as a path segment.
+func (o *options) escapeKey(k string) string {
+	if !o.escape {
+		return k
+	}
+
+	// A numeric key would be read back as an index in plain notation.
+	if _, ok := parseIndex(k); ok && o.notation == PlainNotation {
+		return `\` + k
+	}
+
+	if !strings.ContainsAny(k, `\[]`) && !strings.Contains(k, o.sep) {
+		return k
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(k); {
+		if strings.HasPrefix(k[i:], o.sep) {
+			b.WriteByte('\\')
+			b.WriteString(o.sep)
+			i += len(o.sep)
+			continue
+		}
+
+		switch k[i] {
+		case '\\', '[', ']':
+			b.WriteByte('\\')
+		}
+
+		b.WriteByte(k[i])
+		i++
+	}
+
+	return b.String()
+}
+
+// empty returns the value of an empty collection closed by delim.
+func (o *options) empty(delim json.Delim) interface{} {
+	if !o.keepEmpty {
+		return o.emptyValue
+	}
+
+	if delim == rbrace {
+		return map[string]interface{}{}
+	}
+
+	return []interface{}{}
+}
+
+// ArrayNotation controls how array indices are formatted in flattened keys.
+type ArrayNotation int
+
+const (
+	// BracketNotation appends the index to the key, e.g. "foo[0]".
+	BracketNotation ArrayNotation = iota
+
+	// DottedBracketNotation adds the index as its own segment, e.g. "foo.[0]".
+	DottedBracketNotation
+
+	// PlainNotation adds the bare index as its own segment, e.g. "foo.0".
+	PlainNotation
+)
+
+var notationNames = map[ArrayNotation]string{
+	BracketNotation:       "bracket",
+	DottedBracketNotation: "dotted",
+	PlainNotation:         "plain",
+}
+
+func (n ArrayNotation) String() string {
+	if s, ok := notationNames[n]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("ArrayNotation(%d)", int(n))
+}
+
+// ParseArrayNotation returns the notation for the name "bracket", "dotted"
+// or "plain".
+func ParseArrayNotation(s string) (ArrayNotation, error) {
+	for n, name := range notationNames {
+		if name == s {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("flatjson: unknown array notation %q", s)
+}
+
+// MarshalText encodes the notation as its name.
+func (n ArrayNotation) MarshalText() ([]byte, error) {
+	if _, ok := notationNames[n]; !ok {
+		return nil, fmt.Errorf("flatjson: unknown array notation %d", int(n))
+	}
+
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText decodes the notation from its name.
+func (n *ArrayNotation) UnmarshalText(b []byte) error {
+	v, err := ParseArrayNotation(string(b))
+
+	if err != nil {
+		return err
+	}
+
+	*n = v
+
+	return nil
+}
+
+// WithArrayNotation sets the format of array indices. The default is
+// BracketNotation.
+func WithArrayNotation(n ArrayNotation) Option {
+	return func(o *options) {
+		o.notation = n
+	}
+}
+
+// WithIndexWidth pads array indices with leading zeros to at least n
+// digits, e.g. "hobbies[001]" for a width of 3, so sorting keys as strings
+// matches the numeric order of the indices. Unflatten reads padded indices.
+// Indices are not padded by jq, JSONPath and JSON Pointer keys.
+func WithIndexWidth(n int) Option {
+	return func(o *options) {
+		o.indexWidth = n
+	}
+}
+
+// WithArrayWildcardKeys sets whether array indices are replaced by "*",
+// e.g. "hobbies[*]" rather than "hobbies[0]" and "hobbies[1]", so all the
+// elements of an array share keys. This is meant for discovering the
+// structure of documents with Keys or InferSchema. In map output later
+// elements overwrite earlier ones, and WithStrictKeys reports them as
+// duplicates. jq paths use an empty index, e.g. ".hobbies[]", and the
+// index width is ignored.
+func WithArrayWildcardKeys(wildcard bool) Option {
+	return func(o *options) {
+		o.wildcard = wildcard
+	}
+}
+
+// index returns the path segment for the array index i.
+func (o *options) index(i int) string {
+	n := strconv.Itoa(i)
+
+	// Every element shares the wildcard, which jq writes as an empty index.
+	if o.wildcard {
+		n = "*"
+
+		if o.syntax == jqSyntax {
+			n = ""
+		}
+	}
+
+	// Query syntaxes use plain integers.
+	if o.syntax == defaultSyntax && !o.wildcard && len(n) < o.indexWidth {
+		n = strings.Repeat("0", o.indexWidth-len(n)) + n
+	}
+
+	if o.syntax == jsonPointerSyntax {
+		return "/" + n
+	}
+
+	if o.notation == PlainNotation && o.syntax == defaultSyntax {
+		return n
+	}
+
+	return "[" + n + "]"
+}
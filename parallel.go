@@ -0,0 +1,173 @@
+package flatjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parallelJob is an element of the array to be flattened.
+type parallelJob struct {
+	raw json.RawMessage
+	out chan<- parallelResult
+}
+
+// parallelResult is the encoded flat map of an element and the statistics
+// of flattening it.
+type parallelResult struct {
+	b     []byte
+	stats *stats
+	err   error
+}
+
+// ConvertArrayParallel re-encodes each element of a top-level JSON array
+// into a flat map, written one per line like ConvertMapLines. Elements are
+// flattened concurrently by the given number of workers, or GOMAXPROCS if
+// it is less than one, and written in the order of the input. Functions set
+// by options, such as WithKeyFunc, must be safe to call concurrently.
+func (f *Encoder) ConvertArrayParallel(r io.Reader, workers int) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	// Wait for the reader and workers to stop before returning.
+	defer wg.Wait()
+	defer cancel()
+
+	jobs := make(chan parallelJob)
+
+	// Results are queued in input order as elements are read and filled
+	// in by the workers, which bounds the number of elements in flight.
+	queue := make(chan chan parallelResult, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				b, s, err := f.encodeMapBytes(ctx, j.raw)
+				j.out <- parallelResult{b: b, stats: s, err: err}
+			}
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer close(queue)
+		defer close(jobs)
+
+		err := decodeElements(newJSONDecoder(r, &f.opts, nil), func(raw json.RawMessage) error {
+			out := make(chan parallelResult, 1)
+
+			select {
+			case queue <- out:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			select {
+			case jobs <- parallelJob{raw: raw, out: out}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			return nil
+		})
+
+		// Report the error after the elements before it are written.
+		if err != nil && ctx.Err() == nil {
+			out := make(chan parallelResult, 1)
+			out <- parallelResult{err: err}
+
+			select {
+			case queue <- out:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	for out := range queue {
+		res := <-out
+
+		if res.stats != nil {
+			f.opts.stats.add(res.stats)
+		}
+
+		if res.err != nil {
+			return res.err
+		}
+
+		if _, err := f.w.Write(res.b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeElements calls fn with each element of a top-level array.
+func decodeElements(dec *json.Decoder, fn func(json.RawMessage) error) error {
+	tok, err := dec.Token()
+
+	if err != nil {
+		return newParseError(dec, "", err)
+	}
+
+	if tok != lsquare {
+		return fmt.Errorf("flatjson: expected a top-level array, got %v", tok)
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+
+		if err := dec.Decode(&raw); err != nil {
+			return newParseError(dec, "", err)
+		}
+
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+
+	// Consume the closing delimiter.
+	if _, err := dec.Token(); err != nil {
+		return newParseError(dec, "", err)
+	}
+
+	return nil
+}
+
+// encodeMapBytes flattens a JSON value and returns it encoded as a flat map
+// along with the statistics of flattening it, which are kept apart so the
+// workers do not share them.
+func (f *Encoder) encodeMapBytes(ctx context.Context, raw json.RawMessage) ([]byte, *stats, error) {
+	o := f.opts
+	o.stats = &stats{}
+
+	pairs, err := parseJSON(ctx, bytes.NewReader(raw), &o)
+
+	if err != nil {
+		return nil, o.stats, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := &Encoder{w: buf, opts: o}
+
+	if err := enc.writeMap(pairs); err != nil {
+		return nil, o.stats, err
+	}
+
+	return buf.Bytes(), o.stats, nil
+}
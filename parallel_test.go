@@ -0,0 +1,70 @@
+package flatjson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConvertArrayParallel(t *testing.T) {
+	var (
+		in       strings.Builder
+		expected strings.Builder
+	)
+
+	in.WriteString("[")
+
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			in.WriteString(",")
+		}
+
+		fmt.Fprintf(&in, `{"id": %d, "tags": ["t%d"]}`, i, i)
+		fmt.Fprintf(&expected, `{"id":%d,"tags[0]":"t%d"}`+"\n", i, i)
+	}
+
+	in.WriteString("]")
+
+	for _, workers := range []int{0, 1, 4} {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf)
+
+		if err := enc.ConvertArrayParallel(strings.NewReader(in.String()), workers); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := buf.String(); s != expected.String() {
+			t.Errorf("%d workers: unexpected output %s", workers, s)
+		}
+	}
+}
+
+func TestConvertArrayParallelError(t *testing.T) {
+	inputs := []string{
+		`{"a": 1}`,
+		`[{"a": 1}, {"b": }]`,
+		`[{"a": 1}`,
+	}
+
+	for _, input := range inputs {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, WithStrictKeys(true))
+
+		if err := enc.ConvertArrayParallel(strings.NewReader(input), 2); err == nil {
+			t.Errorf("%s: expected error", input)
+		}
+	}
+
+	// Elements before the error are written.
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	if err := enc.ConvertArrayParallel(strings.NewReader(`[{"a": 1}, {"b": 2}, x]`), 2); err == nil {
+		t.Error("expected error")
+	}
+
+	if s := buf.String(); s != "{\"a\":1}\n{\"b\":2}\n" {
+		t.Errorf("unexpected output %s", s)
+	}
+}
@@ -0,0 +1,49 @@
+package flatjson
+
+import (
+	"io"
+	"strconv"
+)
+
+// PathValue is a value with the unjoined segments of its path, so keys
+// containing the separator are unambiguous.
+type PathValue struct {
+	// Literal map keys and array indices formatted as integers, e.g.
+	// ["hobbies", "0"]. A prefix is the first segment.
+	Path []string
+
+	Value interface{}
+}
+
+// ParsePaths returns the values of a JSON input with their paths. Paths are
+// built from the input, so they are not changed by options that format
+// keys, such as WithSeparator and WithKeyFunc, and a top-level scalar has
+// an empty path.
+func ParsePaths(r io.Reader, opts ...Option) ([]PathValue, error) {
+	// The full slice expression keeps append from writing into spare
+	// capacity of the caller's slice.
+	pairs, err := Parse(r, append(opts[:len(opts):len(opts)], WithPathSegments(true))...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pvs := make([]PathValue, len(pairs))
+
+	for i, p := range pairs {
+		path := make([]string, len(p.segs))
+
+		for j, seg := range p.segs {
+			switch seg := seg.(type) {
+			case int:
+				path[j] = strconv.Itoa(seg)
+			case string:
+				path[j] = seg
+			}
+		}
+
+		pvs[i] = PathValue{Path: path, Value: p.Value}
+	}
+
+	return pvs, nil
+}
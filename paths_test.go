@@ -0,0 +1,53 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePaths(t *testing.T) {
+	input := `{"a.b": {"c": [1, {"d[0]": true}]}, "e": []}`
+
+	pvs, err := ParsePaths(strings.NewReader(input), WithSeparator("/"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []PathValue{
+		{[]string{"a.b", "c", "0"}, json.Number("1")},
+		{[]string{"a.b", "c", "1", "d[0]"}, true},
+		{[]string{"e"}, nil},
+	}
+
+	if !reflect.DeepEqual(pvs, expected) {
+		t.Errorf("expected %v, got %v", expected, pvs)
+	}
+
+	pvs, err = ParsePaths(strings.NewReader(`"x"`), WithPrefix("p"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(pvs, []PathValue{{[]string{"p"}, "x"}}) {
+		t.Errorf("expected the prefix as the path, got %v", pvs)
+	}
+}
+
+func TestParsePathsOptionsUnchanged(t *testing.T) {
+	// Spare capacity of the options must not be written to.
+	opts := make([]Option, 1, 2)
+	opts[0] = WithSeparator("/")
+	spare := opts[:2]
+
+	if _, err := ParsePaths(strings.NewReader(`{"a": 1}`), opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	if spare[1] != nil {
+		t.Error("expected the caller's options to be unchanged")
+	}
+}
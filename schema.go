@@ -0,0 +1,48 @@
+package flatjson
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// InferSchema returns the JSON type of the values of each key a JSON input
+// is flattened to, such as "string" or "number", as named by Kind. Array
+// indices are replaced by a wildcard, e.g. "hobbies[*]", so the types of
+// elements are merged. A key with values of more than one type lists each
+// separated by "|", e.g. "null|number". Empty maps and arrays are typed
+// "object" and "array" rather than being collapsed to null, as are values
+// kept whole, such as past WithMaxDepth.
+func InferSchema(r io.Reader, opts ...Option) (map[string]string, error) {
+	o := newOptions(opts)
+	o.keepEmpty = true
+	o.wildcard = true
+
+	// Set of kinds seen by key.
+	kinds := make(map[string]uint)
+
+	err := parseFunc(context.Background(), r, &o, func(p *Pair) error {
+		kinds[p.Key] |= 1 << p.Kind()
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	schema := make(map[string]string, len(kinds))
+
+	for key, set := range kinds {
+		var names []string
+
+		for k := NullKind; k <= ArrayKind; k++ {
+			if set&(1<<k) != 0 {
+				names = append(names, k.String())
+			}
+		}
+
+		schema[key] = strings.Join(names, "|")
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,66 @@
+package flatjson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	input := `{
+		"name": "Bob",
+		"age": 30,
+		"tags": [],
+		"items": [{"id": 1, "price": 1.5}, {"id": 2, "price": null, "note": "x"}],
+		"matrix": [[1, 2], [true]],
+		"meta": {}
+	}
+	{"name": null, "extra": false}`
+
+	schema, err := InferSchema(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"name":           "null|string",
+		"age":            "number",
+		"tags":           "array",
+		"items[*].id":    "number",
+		"items[*].price": "null|number",
+		"items[*].note":  "string",
+		"matrix[*][*]":   "boolean|number",
+		"meta":           "object",
+		"extra":          "boolean",
+	}
+
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("expected %v, got %v", expected, schema)
+	}
+
+	schema, err = InferSchema(strings.NewReader(`{"a": [{"b": 1}], "c": {"d": [2]}}`), WithMaxDepth(1), WithArrayNotation(PlainNotation))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[string]string{
+		"a": "array",
+		"c": "object",
+	}
+
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("expected %v, got %v", expected, schema)
+	}
+
+	schema, err = InferSchema(strings.NewReader(`[{"a": 1}, {"a": "x"}]`), WithArrayNotation(PlainNotation))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(schema, map[string]string{"*.a": "number|string"}) {
+		t.Errorf("unexpected schema %v", schema)
+	}
+}
@@ -0,0 +1,145 @@
+package flatjson
+
+import (
+	"encoding/json"
+)
+
+// WithUnwrapSingletonArrays sets whether an array with exactly one element
+// is flattened as if it were the element itself, e.g. {"foo": [1]} becomes
+// "foo" rather than "foo[0]", to normalize data that inconsistently holds
+// one value or many. Empty arrays and those with more elements are
+// unaffected. The first element of each array is read ahead to find
+// whether it is the only one.
+func WithUnwrapSingletonArrays(unwrap bool) Option {
+	return func(o *options) {
+		o.unwrapSingle = unwrap
+	}
+}
+
+// aheadToken is a token read ahead of the parser and its offset.
+type aheadToken struct {
+	tok json.Token
+	off int64
+}
+
+// token returns the next token and its offset, if offsets are recorded.
+// Tokens read ahead are returned first, followed by the error that stopped
+// reading ahead, if any.
+func (p *parser) token() (json.Token, int64, error) {
+	if len(p.ahead) > 0 {
+		t := p.ahead[0]
+		p.ahead = p.ahead[1:]
+
+		return t.tok, t.off, nil
+	}
+
+	if p.aheadErr != nil {
+		return nil, 0, p.aheadErr
+	}
+
+	prev := p.dec.InputOffset()
+
+	tok, err := p.dec.Token()
+
+	var off int64
+
+	if err == nil && p.rec != nil {
+		off = p.rec.start(prev, p.dec.InputOffset())
+	}
+
+	return tok, off, err
+}
+
+// reader returns the reader of the remaining tokens of the input, which
+// replays the tokens read ahead if there may be any.
+func (p *parser) reader() tokenReader {
+	if p.o.unwrapSingle {
+		return aheadReader{p: p}
+	}
+
+	return p.dec
+}
+
+// aheadReader reads tokens through the parser.
+type aheadReader struct {
+	p *parser
+}
+
+func (r aheadReader) Token() (json.Token, error) {
+	tok, _, err := r.p.token()
+	return tok, err
+}
+
+func (r aheadReader) More() bool {
+	if len(r.p.ahead) > 0 {
+		tok := r.p.ahead[0].tok
+		return tok != rbrace && tok != rsquare
+	}
+
+	// The error is returned by the next token.
+	if r.p.aheadErr != nil {
+		return true
+	}
+
+	return r.p.dec.More()
+}
+
+func (r aheadReader) InputOffset() int64 {
+	return r.p.dec.InputOffset()
+}
+
+// readAhead reads the first element of an array just opened and the token
+// after it, which are replayed afterwards, and returns true if the element
+// is the only one.
+func (p *parser) readAhead() bool {
+	var (
+		toks  []aheadToken
+		depth int
+	)
+
+	// Tokens read are put back ahead of any that remain.
+	defer func() {
+		p.ahead = append(toks, p.ahead...)
+	}()
+
+	for {
+		tok, off, err := p.token()
+
+		if err != nil {
+			p.aheadErr = err
+			return false
+		}
+
+		toks = append(toks, aheadToken{tok: tok, off: off})
+
+		switch tok {
+		case lbrace, lsquare:
+			depth++
+			continue
+		case rbrace, rsquare:
+			depth--
+		}
+
+		// The end of an empty array.
+		if depth < 0 {
+			return false
+		}
+
+		// Once the element is complete, the token after it tells whether
+		// it is the last.
+		if depth == 0 {
+			break
+		}
+	}
+
+	tok, off, err := p.token()
+
+	if err != nil {
+		p.aheadErr = err
+		return false
+	}
+
+	toks = append(toks, aheadToken{tok: tok, off: off})
+
+	return tok == rsquare
+}
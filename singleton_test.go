@@ -0,0 +1,76 @@
+package flatjson
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnwrapSingletonArrays(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Options  []Option
+		Expected string
+	}{
+		{`{"foo": [1], "bar": [1, 2], "baz": []}`, nil, `[["foo",1],["bar[0]",1],["bar[1]",2],["baz",null]]`},
+		{`{"a": [{"b": [[true]]}], "c": [[1, 2]]}`, nil, `[["a.b",true],["c[0]",1],["c[1]",2]]`},
+		{`{"a": [[1], [2, [3]]]}`, nil, `[["a[0]",1],["a[1][0]",2],["a[1][1]",3]]`},
+		{`{"a": [{"b": 1}]}`, []Option{WithArrayNotation(PlainNotation)}, `[["a.b",1]]`},
+		{`{"a": ["x"], "b": ["y", "z"]}`, []Option{WithArrayNotation(PlainNotation)}, `[["a","x"],["b.0","y"],["b.1","z"]]`},
+		{`{"a": [{"b": 1}]}`, []Option{WithJSONPointer(true)}, `[["/a/b",1]]`},
+		{`[{"a": 1}]`, []Option{WithPrefix("p")}, `[["p.a",1]]`},
+		{`[5]`, nil, `[["",5]]`},
+		{`[[{"a": 1}]]`, []Option{WithArrayNotation(PlainNotation)}, `[["a",1]]`},
+		{`{"": [{"a": 1}]}`, nil, `[[".a",1]]`},
+		{`{"a": [{"b": {"c": 1}}]}`, []Option{WithMaxDepth(2)}, `[["a",{"b":{"c":1}}]]`},
+		{`{"a": [[1]]}`, []Option{WithFlattenArrays(false)}, `[["a",[[1]]]]`},
+		{`{"a": [1]}`, []Option{WithPathSegments(true)}, `[[["a"],1]]`},
+		{`{"a": [1]}`, []Option{WithUnwrapSingletonArrays(false)}, `[["a[0]",1]]`},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, append([]Option{WithUnwrapSingletonArrays(true)}, test.Options...)...)
+
+		if err := enc.ConvertArray(strings.NewReader(test.Input)); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := strings.TrimSpace(buf.String()); s != test.Expected {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Expected, s)
+		}
+	}
+}
+
+func TestUnwrapSingletonArraysOffsets(t *testing.T) {
+	input := `{"a": [ "x" ], "b": [1, {"c": 2}]}`
+
+	pairs, err := Parse(strings.NewReader(input), WithUnwrapSingletonArrays(true), WithOffsets(true))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int64{
+		"a":      8,
+		"b[0]":   21,
+		"b[1].c": 30,
+	}
+
+	for _, p := range pairs {
+		if p.Offset != expected[p.Key] {
+			t.Errorf("%s: expected offset %d, got %d", p.Key, expected[p.Key], p.Offset)
+		}
+	}
+
+	// Errors within the element read ahead are reported where they occur,
+	// though the element is indexed as it is not known to be the only one.
+	_, err = Parse(strings.NewReader(`{"a": [{"b": x}]}`), WithUnwrapSingletonArrays(true))
+
+	var perr *ParseError
+
+	if !errors.As(err, &perr) || perr.Path != "a[0].b" {
+		t.Errorf("expected a parse error at a[0].b, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package flatjson
+
+// Stats describes the values flattened by an Encoder.
+type Stats struct {
+	// Number of pairs flattened.
+	Pairs int
+
+	// Deepest nesting of maps and arrays, where a top-level scalar has a
+	// depth of zero and {"a": 1} a depth of one.
+	MaxDepth int
+
+	// Number of pairs by the kind of their value.
+	Kinds map[Kind]int
+
+	// Number of maps and arrays that were flattened, including empty ones.
+	// Those kept whole, such as past WithMaxDepth, are values counted in
+	// Kinds instead.
+	Objects int
+	Arrays  int
+}
+
+// stats accumulates the statistics of flattened values.
+type stats struct {
+	pairs   int
+	depth   int
+	kinds   [ArrayKind + 1]int
+	objects int
+	arrays  int
+}
+
+// add adds the statistics of other values.
+func (s *stats) add(o *stats) {
+	s.pairs += o.pairs
+	s.objects += o.objects
+	s.arrays += o.arrays
+
+	if o.depth > s.depth {
+		s.depth = o.depth
+	}
+
+	for k, n := range o.kinds {
+		s.kinds[k] += n
+	}
+}
+
+// Stats returns the statistics of the values flattened by the Encoder since
+// it was created or last Reset, such as the number of pairs and the deepest
+// nesting, so the complexity of documents can be reported without a second
+// pass. Values that fail to flatten are counted up to the error.
+func (f *Encoder) Stats() Stats {
+	s := f.opts.stats
+
+	// An Encoder that was not created by NewEncoder has no statistics.
+	if s == nil {
+		s = &stats{}
+	}
+
+	st := Stats{
+		Pairs:    s.pairs,
+		MaxDepth: s.depth,
+		Kinds:    make(map[Kind]int),
+		Objects:  s.objects,
+		Arrays:   s.arrays,
+	}
+
+	for k, n := range s.kinds {
+		if n > 0 {
+			st.Kinds[Kind(k)] = n
+		}
+	}
+
+	return st
+}
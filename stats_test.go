@@ -0,0 +1,116 @@
+package flatjson
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	input := `{"name": "Bob", "tags": [], "address": {"geo": {"lat": 1.5}, "zip": null}, "ok": [true, [1]]}`
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	if err := enc.ConvertMap(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Stats{
+		Pairs:    6,
+		MaxDepth: 3,
+		Kinds: map[Kind]int{
+			StringKind: 1,
+			NullKind:   2,
+			NumberKind: 2,
+			BoolKind:   1,
+		},
+		Objects: 3,
+		Arrays:  3,
+	}
+
+	if s := enc.Stats(); !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+
+	// Statistics accumulate until the encoder is reset.
+	if err := enc.ConvertArray(strings.NewReader(`[{"a": 1}]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := enc.Stats(); s.Pairs != 7 || s.Objects != 4 || s.Arrays != 4 || s.Kinds[NumberKind] != 3 {
+		t.Errorf("expected accumulated statistics, got %+v", s)
+	}
+
+	enc.Reset(io.Discard)
+
+	if s := enc.Stats(); !reflect.DeepEqual(s, Stats{Kinds: map[Kind]int{}}) {
+		t.Errorf("expected statistics to be cleared, got %+v", s)
+	}
+
+	// Values kept whole are counted as values.
+	enc = NewEncoder(io.Discard, WithMaxDepth(1), WithArraysAsArrays(true))
+
+	if err := enc.ConvertMap(strings.NewReader(`{"a": {"b": [1]}, "c": [{"d": 2}]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = Stats{
+		Pairs:    2,
+		MaxDepth: 1,
+		Kinds:    map[Kind]int{ObjectKind: 1, ArrayKind: 1},
+		Objects:  1,
+	}
+
+	if s := enc.Stats(); !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+}
+
+func TestStatsZeroEncoder(t *testing.T) {
+	var enc Encoder
+
+	if s := enc.Stats(); !reflect.DeepEqual(s, Stats{Kinds: map[Kind]int{}}) {
+		t.Errorf("expected empty statistics, got %+v", s)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc.Reset(buf)
+
+	// Reset gives a zero encoder the default options.
+	if err := enc.ConvertMap(strings.NewReader(`{"a": {"b": 1}, "c": [1, 2]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"a.b":1,"c[0]":1,"c[1]":2}`
+
+	if s := strings.TrimSpace(buf.String()); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	if s := enc.Stats(); s.Pairs != 3 {
+		t.Errorf("expected 3 pairs, got %+v", s)
+	}
+}
+
+func TestStatsParallel(t *testing.T) {
+	enc := NewEncoder(io.Discard)
+
+	if err := enc.ConvertArrayParallel(strings.NewReader(`[{"a": [1, 2]}, {"b": {"c": "x"}}, 3]`), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Stats{
+		Pairs:    4,
+		MaxDepth: 2,
+		Kinds:    map[Kind]int{NumberKind: 3, StringKind: 1},
+		Objects:  3,
+		Arrays:   1,
+	}
+
+	if s := enc.Stats(); !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+}
@@ -0,0 +1,236 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// structField is an encoded field of a struct.
+type structField struct {
+	// Index sequence of the field, through embedded structs.
+	index []int
+
+	name      string
+	omitEmpty bool
+}
+
+// Fields of struct types by type. A nil slice denotes a struct that is
+// encoded by encoding/json.
+var structFields sync.Map
+
+// FlattenStruct flattens a struct, or a pointer to one, into a map of keys
+// to values like Flatten. Structs are walked directly rather than encoded
+// and decoded as JSON, and their fields are named as encoding/json names
+// them:
+//
+//   - Exported fields are named by the name in their json tag, or by the
+//     field name if the tag has none.
+//   - Fields tagged "-" are skipped.
+//   - Fields tagged omitempty are skipped if false, 0, nil, or an empty
+//     string, slice, map or array.
+//   - Fields of embedded structs without a tag name are promoted to the
+//     embedding struct. Fields of a nil embedded pointer are skipped.
+//
+// Structs with other tag options, such as string, fields promoted with the
+// same name, or other embedded types, are encoded by encoding/json, which
+// produces the same keys. So are types that implement json.Marshaler or
+// encoding.TextMarshaler.
+func FlattenStruct(v interface{}, opts ...Option) (map[string]interface{}, error) {
+	if reflect.Indirect(reflect.ValueOf(v)).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("flatjson: expected a struct, got %T", v)
+	}
+
+	return Flatten(v, opts...)
+}
+
+// appendStruct appends the tokens of a struct, or encodes it if its fields
+// are not supported.
+func appendStruct(toks []json.Token, v reflect.Value, depth int) ([]json.Token, error) {
+	fields := typeFields(v.Type())
+
+	if fields == nil {
+		return appendEncoded(toks, v.Interface())
+	}
+
+	toks = append(toks, lbrace)
+
+Fields:
+	for _, f := range fields {
+		fv := v
+
+		for _, i := range f.index {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue Fields
+				}
+
+				fv = fv.Elem()
+			}
+
+			fv = fv.Field(i)
+		}
+
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		var err error
+
+		toks = append(toks, validString(f.name))
+
+		if toks, err = appendValue(toks, fv, depth+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(toks, rbrace), nil
+}
+
+// typeFields returns the encoded fields of a struct type, or nil if the
+// struct is left to encoding/json.
+func typeFields(t reflect.Type) []structField {
+	if f, ok := structFields.Load(t); ok {
+		return f.([]structField)
+	}
+
+	fields, ok := collectFields(t, nil, map[reflect.Type]bool{t: true})
+
+	if ok {
+		seen := make(map[string]bool, len(fields))
+
+		for _, f := range fields {
+			if seen[f.name] {
+				ok = false
+				break
+			}
+
+			seen[f.name] = true
+		}
+	}
+
+	if !ok {
+		fields = nil
+	} else if fields == nil {
+		fields = []structField{}
+	}
+
+	structFields.Store(t, fields)
+
+	return fields
+}
+
+// collectFields returns the encoded fields of a struct type in order,
+// including those of embedded structs. It returns false if a field is not
+// supported.
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool) ([]structField, bool) {
+	var fields []structField
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if name != "" && !isValidTag(name) {
+			return nil, false
+		}
+
+		for _, opt := range strings.Split(opts, ",") {
+			if opt != "" && opt != "omitempty" {
+				return nil, false
+			}
+		}
+
+		idx := append(append([]int(nil), index...), i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			// Other embedded types, and structs that encode themselves, are
+			// left to encoding/json.
+			if ft.Kind() != reflect.Struct || implementsMarshaler(ft) || visited[ft] {
+				return nil, false
+			}
+
+			visited[ft] = true
+			embedded, ok := collectFields(ft, idx, visited)
+			delete(visited, ft)
+
+			if !ok {
+				return nil, false
+			}
+
+			fields = append(fields, embedded...)
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, structField{
+			index:     idx,
+			name:      name,
+			omitEmpty: strings.Contains(opts, "omitempty"),
+		})
+	}
+
+	return fields, true
+}
+
+// isValidTag returns true if encoding/json uses the name in a tag rather
+// than the field name.
+func isValidTag(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// implementsMarshaler returns true if the type or a pointer to it encodes
+// itself.
+func implementsMarshaler(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+
+	return t.Implements(marshalerType) || t.Implements(textMarshalerType) ||
+		pt.Implements(marshalerType) || pt.Implements(textMarshalerType)
+}
+
+// isEmptyValue returns true if the value is omitted by omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package flatjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type structBase struct {
+	ID      int    `json:"id"`
+	Created string `json:"created,omitempty"`
+}
+
+type structMeta struct {
+	Tags []string `json:"tags"`
+}
+
+type structUser struct {
+	structBase
+	*structMeta
+
+	Name     string            `json:"name"`
+	Email    string            `json:"email,omitempty"`
+	Age      int               `json:"age,omitempty"`
+	Admin    bool              `json:"-"`
+	Dash     bool              `json:"-,"`
+	Address  *structAddress    `json:"address"`
+	Extra    map[string]string `json:"extra,omitempty"`
+	Joined   time.Time         `json:"joined"`
+	Untagged float64
+	private  int
+}
+
+type structAddress struct {
+	City string `json:"city"`
+	Zip  int    `json:"zip,string"`
+}
+
+type structConflict struct {
+	structBase
+	ID string `json:"id"`
+}
+
+func TestFlattenStruct(t *testing.T) {
+	values := []interface{}{
+		structUser{
+			structBase: structBase{ID: 1},
+			Name:       "Bob",
+			Address:    &structAddress{City: "Boresville", Zip: 13943},
+			Joined:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Untagged:   1.5,
+			private:    1,
+		},
+		&structUser{
+			structBase: structBase{ID: 2, Created: "today"},
+			structMeta: &structMeta{Tags: []string{"a"}},
+			Email:      "bob@example.com",
+			Age:        30,
+			Admin:      true,
+			Dash:       true,
+			Extra:      map[string]string{"k": "v"},
+		},
+		structConflict{structBase: structBase{ID: 1}, ID: "x"},
+		struct{}{},
+	}
+
+	for _, v := range values {
+		m, err := FlattenStruct(v)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The same map as flattening the encoded struct.
+		b, err := json.Marshal(v)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pairs, err := ParseBytes(b)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if exp := mapPairs(pairs).toMap(); !reflect.DeepEqual(m, exp) {
+			t.Errorf("expected %v, got %v", exp, m)
+		}
+	}
+
+	if _, err := FlattenStruct(map[string]int{"a": 1}); err == nil {
+		t.Error("expected an error for a map")
+	}
+
+	// Structs that are walked directly are not encoded.
+	if f := typeFields(reflect.TypeOf(structUser{})); f == nil {
+		t.Error("expected fields of structUser")
+	}
+
+	if f := typeFields(reflect.TypeOf(structConflict{})); f != nil {
+		t.Error("expected structConflict to be encoded")
+	}
+}
@@ -0,0 +1,69 @@
+package flatjson
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// tsvEscaper escapes the characters that delimit fields and lines in keys.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// EncodeTSV encodes a value as tab-separated lines of keys and JSON-encoded
+// values, e.g. "address.city\t\"Boresville\"", without a header. Tabs, line
+// breaks and backslashes in keys are escaped with a backslash as in
+// "\t", "\n", "\r" and "\\". Values never contain them. Lines end with the
+// terminator set by WithLineTerminator.
+func (f *Encoder) EncodeTSV(v interface{}) error {
+	pairs, err := parseValueOf(v, &f.opts)
+
+	if err != nil {
+		return err
+	}
+
+	return f.writeTSV(pairs)
+}
+
+// ConvertTSV re-encodes a JSON value as tab-separated lines of keys and
+// JSON-encoded values like EncodeTSV.
+func (f *Encoder) ConvertTSV(r io.Reader) error {
+	pairs, err := parseJSON(context.Background(), r, &f.opts)
+
+	if err != nil {
+		return err
+	}
+
+	return f.writeTSV(pairs)
+}
+
+// writeTSV writes the pairs as TSV lines.
+func (f *Encoder) writeTSV(pairs []*Pair) error {
+	if f.opts.sortKeys {
+		sortPairs(pairs)
+	}
+
+	newline := f.opts.newline
+
+	if newline == "" {
+		newline = "\n"
+	}
+
+	w := bufio.NewWriter(f.w)
+
+	for _, p := range f.opts.outputPairs(pairs) {
+		b, err := json.Marshal(p.Value)
+
+		if err != nil {
+			return err
+		}
+
+		w.WriteString(tsvEscaper.Replace(p.Key))
+		w.WriteByte('\t')
+		w.Write(b)
+		w.WriteString(newline)
+	}
+
+	return w.Flush()
+}
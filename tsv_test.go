@@ -0,0 +1,71 @@
+package flatjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeTSV(t *testing.T) {
+	v := map[string]interface{}{
+		"name": "Bob\tSmith\n",
+		"address": map[string]interface{}{
+			"zip\tcode": 13943,
+		},
+		`a\b`: []interface{}{true, nil},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithSortKeys(true), WithKeyEscaping(false))
+
+	if err := enc.EncodeTSV(v); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "a\\\\b[0]\ttrue\n" +
+		"a\\\\b[1]\tnull\n" +
+		"address.zip\\tcode\t13943\n" +
+		"name\t\"Bob\\tSmith\\n\"\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestConvertTSV(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithLineTerminator("\r\n"))
+
+	if err := enc.ConvertTSV(strings.NewReader(`{"b": {"c": "x"}, "a": [1]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "b.c\t\"x\"\r\na[0]\t1\r\n"
+
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestConvertTSVValues(t *testing.T) {
+	tests := []struct {
+		Options  []Option
+		Expected string
+	}{
+		{[]Option{WithStringValues(true)}, "a\t\"1\"\nb\t\"true\"\nc\t\"\"\n"},
+		{[]Option{WithTypedValues(true)}, "a\t{\"type\":\"number\",\"value\":1}\nb\t{\"type\":\"boolean\",\"value\":true}\nc\t{\"type\":\"null\",\"value\":null}\n"},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, test.Options...)
+
+		if err := enc.ConvertTSV(strings.NewReader(`{"a": 1, "b": true, "c": null}`)); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != test.Expected {
+			t.Errorf("expected %q, got %q", test.Expected, buf.String())
+		}
+	}
+}
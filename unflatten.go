@@ -0,0 +1,330 @@
+package flatjson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// step is a single component of a flattened key, either a map key or
+// an array index.
+type step struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// parseIndex parses an array index made up only of digits.
+func parseIndex(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+
+	return n, err == nil
+}
+
+// scanIndices scans a run of bracketed indices, e.g. "[0][1]", at the start
+// of s. The run must end the segment, otherwise the brackets are part of the
+// key and zero width is returned.
+func scanIndices(s string, sep string) ([]int, int) {
+	var (
+		idxs []int
+		w    int
+	)
+
+	for w < len(s) && s[w] == '[' {
+		j := strings.IndexByte(s[w:], ']')
+
+		if j < 0 {
+			return nil, 0
+		}
+
+		n, ok := parseIndex(s[w+1 : w+j])
+
+		if !ok {
+			return nil, 0
+		}
+
+		idxs = append(idxs, n)
+		w += j + 1
+	}
+
+	if w < len(s) && !strings.HasPrefix(s[w:], sep) {
+		return nil, 0
+	}
+
+	return idxs, w
+}
+
+// pointerUnescaper reverses pointerEscaper. Replacements are made in a
+// single pass, so "~01" becomes "~1" rather than "/".
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// splitPointer splits a JSON Pointer into steps. Numeric reference tokens
+// are taken to be array indices.
+func splitPointer(key string) []step {
+	if key == "" {
+		return nil
+	}
+
+	var steps []step
+
+	for _, tok := range strings.Split(strings.TrimPrefix(key, "/"), "/") {
+		if n, ok := parseIndex(tok); ok {
+			steps = append(steps, step{index: n, isIdx: true})
+		} else {
+			steps = append(steps, step{key: pointerUnescaper.Replace(tok)})
+		}
+	}
+
+	return steps
+}
+
+// splitKey splits a flattened key into the steps needed to reach the value.
+func splitKey(key string, o *options) []step {
+	if o.syntax == jsonPointerSyntax {
+		return splitPointer(key)
+	}
+
+	var steps []step
+
+	for i := 0; ; i += len(o.sep) {
+		var (
+			name    []byte
+			idxs    []int
+			escaped bool
+		)
+
+		// Scan up to the next unescaped separator.
+		for i < len(key) && !strings.HasPrefix(key[i:], o.sep) {
+			if o.escape && key[i] == '\\' && i+1 < len(key) {
+				escaped = true
+				i++
+
+				if strings.HasPrefix(key[i:], o.sep) {
+					name = append(name, o.sep...)
+					i += len(o.sep)
+				} else {
+					name = append(name, key[i])
+					i++
+				}
+
+				continue
+			}
+
+			// Trailing array indices, e.g. "hobbies[0][1]".
+			if key[i] == '[' {
+				if n, w := scanIndices(key[i:], o.sep); w > 0 {
+					idxs = n
+					i += w
+					continue
+				}
+			}
+
+			name = append(name, key[i])
+			i++
+		}
+
+		// Plain notation stores indices as bare numeric segments.
+		if n, ok := parseIndex(string(name)); ok && o.notation == PlainNotation && !escaped && len(idxs) == 0 {
+			steps = append(steps, step{index: n, isIdx: true})
+		} else if len(name) > 0 || len(idxs) == 0 {
+			// A bare index such as "[0]" or ".[0]" has no key of its own.
+			steps = append(steps, step{key: string(name)})
+		}
+
+		for _, n := range idxs {
+			steps = append(steps, step{index: n, isIdx: true})
+		}
+
+		if i >= len(key) {
+			break
+		}
+	}
+
+	return steps
+}
+
+// Number of nulls that may be left in the gaps of arrays in total, beyond
+// the elements indexed by keys.
+const maxIndexGap = 1 << 16
+
+// insert sets the value at the path described by steps within the container
+// and returns the (possibly reallocated) container. Arrays may only grow by
+// the remaining room, which is reduced by the elements added.
+func insert(c interface{}, key string, steps []step, v interface{}, room *int) (interface{}, error) {
+	if len(steps) == 0 {
+		if c != nil {
+			return nil, fmt.Errorf("flatjson: conflicting value for key %q", key)
+		}
+
+		return v, nil
+	}
+
+	s := steps[0]
+
+	if s.isIdx {
+		if c == nil {
+			c = []interface{}{}
+		}
+
+		a, ok := c.([]interface{})
+
+		if !ok {
+			return nil, fmt.Errorf("flatjson: key %q indexes a non-array value", key)
+		}
+
+		// Grow the array, leaving nulls in any gaps.
+		if s.index >= len(a) {
+			if s.index-len(a) >= *room {
+				return nil, fmt.Errorf("flatjson: array index %d out of range for key %q", s.index, key)
+			}
+
+			*room -= s.index + 1 - len(a)
+
+			// Capacity is doubled so keys sorted out of numeric order,
+			// e.g. "a[10]" before "a[2]", do not copy the array each time.
+			if s.index < cap(a) {
+				a = a[:s.index+1]
+			} else {
+				n := 2 * cap(a)
+
+				if n <= s.index {
+					n = s.index + 1
+				}
+
+				dest := make([]interface{}, s.index+1, n)
+				copy(dest, a)
+				a = dest
+			}
+		}
+
+		x, err := insert(a[s.index], key, steps[1:], v, room)
+
+		if err != nil {
+			return nil, err
+		}
+
+		a[s.index] = x
+
+		return a, nil
+	}
+
+	if c == nil {
+		c = make(map[string]interface{})
+	}
+
+	m, ok := c.(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("flatjson: key %q descends into a non-object value", key)
+	}
+
+	x, err := insert(m[s.key], key, steps[1:], v, room)
+
+	if err != nil {
+		return nil, err
+	}
+
+	m[s.key] = x
+
+	return m, nil
+}
+
+// unflatten reconstructs a nested value from a flat map.
+func unflatten(flat map[string]interface{}, o *options) (interface{}, error) {
+	// Sort the keys so conflicts are reported deterministically.
+	keys := make([]string, 0, len(flat))
+
+	for k := range flat {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	// Every element of an array is reached by at least one key, so arrays
+	// are only grown to hold as many elements as there are indices in the
+	// keys, plus limited gaps. A small document cannot allocate huge ones.
+	paths := make([][]step, len(keys))
+	room := maxIndexGap
+
+	for i, k := range keys {
+		paths[i] = splitKey(k, o)
+
+		for _, s := range paths[i] {
+			if s.isIdx {
+				room++
+			}
+		}
+	}
+
+	var (
+		root interface{}
+		err  error
+	)
+
+	for i, k := range keys {
+		root, err = insert(root, k, paths[i], flat[k], &room)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if root == nil {
+		root = map[string]interface{}{}
+	}
+
+	return root, nil
+}
+
+// Decode reads a flat JSON map and writes the reconstructed nested value.
+// A map wrapped with a header written by WithMetaHeader is unflattened with
+// the separator, array notation and key escaping in the header.
+func (f *Encoder) Decode(r io.Reader) error {
+	var flat map[string]interface{}
+
+	if err := newJSONDecoder(r, &f.opts, nil).Decode(&flat); err != nil {
+		return err
+	}
+
+	flat, o := readMeta(flat, f.opts)
+
+	v, err := unflatten(flat, &o)
+
+	if err != nil {
+		return err
+	}
+
+	return f.jsonEncoder().Encode(v)
+}
+
+// Unflatten reconstructs nested JSON from a flat JSON map.
+//
+// A document flattened with WithEmptyCollections(true) and the same array
+// notation and separator is reconstructed exactly, except for a top-level
+// scalar, which becomes a map with an empty key, and an empty top-level
+// array, which becomes an empty map. Without WithEmptyCollections, empty
+// maps and arrays are reconstructed as null, and without key escaping keys
+// containing the separator or brackets are split into nested values.
+func Unflatten(r io.Reader) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	if err := enc.Decode(r); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
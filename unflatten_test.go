@@ -0,0 +1,251 @@
+package flatjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var unflattenTests = []jsonTest{
+	{
+		Name:     "empty map",
+		Input:    `{}`,
+		Expected: `{}`,
+	},
+	{
+		Name:     "nested map",
+		Input:    `{"address.street": "123 Main", "address.city": "Boresville"}`,
+		Expected: `{"address": {"street": "123 Main", "city": "Boresville"}}`,
+	},
+	{
+		Name:     "array value",
+		Input:    `{"hobbies[0]": "tennis", "hobbies[1]": "coding"}`,
+		Expected: `{"hobbies": ["tennis", "coding"]}`,
+	},
+	{
+		Name:     "dotted array value",
+		Input:    `{"hobbies.[0]": "tennis"}`,
+		Expected: `{"hobbies": ["tennis"]}`,
+	},
+	{
+		Name:     "top-level array",
+		Input:    `{"[0]": 1, "[1]": 2}`,
+		Expected: `[1, 2]`,
+	},
+	{
+		Name:     "sparse array",
+		Input:    `{"a[2]": 3, "a[0]": 1}`,
+		Expected: `{"a": [1, null, 3]}`,
+	},
+	{
+		Name:     "three-deep nested arrays",
+		Input:    `{"[0][0][0]": 1, "[1][0][1]": 2}`,
+		Expected: `[[[1]], [[null, 2]]]`,
+	},
+	{
+		Name:     "nested arrays",
+		Input:    `{"a[0][1]": 1, "a[1][0].b": 2}`,
+		Expected: `{"a": [[null, 1], [{"b": 2}]]}`,
+	},
+}
+
+func TestUnflatten(t *testing.T) {
+	for _, test := range unflattenTests {
+		var expected, actual interface{}
+
+		if err := json.Unmarshal([]byte(test.Expected), &expected); err != nil {
+			panic(err)
+		}
+
+		b, err := Unflatten(strings.NewReader(test.Input))
+
+		if err != nil {
+			t.Errorf("%s: %s", test.Name, err)
+			continue
+		}
+
+		if err := json.Unmarshal(b, &actual); err != nil {
+			t.Errorf("%s: %s", test.Name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %s, got %s", test.Name, test.Expected, b)
+		}
+	}
+}
+
+func TestUnflattenConflict(t *testing.T) {
+	inputs := []string{
+		`{"a": 1, "a.b": 2}`,
+		`{"a": 1, "a[0]": 2}`,
+		`{"a.b": 1, "a[0]": 2}`,
+	}
+
+	for _, input := range inputs {
+		if _, err := Unflatten(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected conflict error", input)
+		}
+	}
+}
+
+func TestUnflattenIndexRange(t *testing.T) {
+	inputs := map[string]string{
+		`{"a[99999999999]": 1}`:         "flatjson: array index 99999999999 out of range",
+		`{"a[9223372036854775807]": 1}`: "flatjson: array index 9223372036854775807 out of range",
+		`{"a[0]": 1, "b[70000]": 2}`:    "flatjson: array index 70000 out of range",
+	}
+
+	for input, expected := range inputs {
+		_, err := Unflatten(strings.NewReader(input))
+
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%s: expected error %q, got %v", input, expected, err)
+		}
+	}
+
+	// Gaps within the limit are filled with nulls, and dense arrays are
+	// limited only by their number of keys.
+	b, err := Unflatten(strings.NewReader(`{"a[3]": 1, "b[1][2]": true}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"a":[null,null,null,1],"b":[null,[null,null,true]]}`
+
+	if s := strings.TrimSpace(string(b)); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	flat := make(map[string]interface{})
+
+	for i := 0; i < 2*maxIndexGap; i++ {
+		flat[fmt.Sprintf("a[%d]", i)] = i
+	}
+
+	v, err := unflatten(flat, &options{sep: pathd, escape: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a := v.(map[string]interface{})["a"].([]interface{}); len(a) != 2*maxIndexGap {
+		t.Errorf("expected %d elements, got %d", 2*maxIndexGap, len(a))
+	}
+}
+
+func TestDecodeIndexRange(t *testing.T) {
+	// The CLI's -unflatten mode decodes untrusted input this way.
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	err := enc.Decode(strings.NewReader(`{"a[99999999999]": 1}`))
+
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output, got %s", buf)
+	}
+}
+
+func TestUnflattenSeparator(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithSeparator("/"))
+
+	if err := enc.Decode(strings.NewReader(`{"example.com/ttl": 300}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(buf.String()); s != `{"example.com":{"ttl":300}}` {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestUnflattenPlainNotation(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, WithArrayNotation(PlainNotation))
+
+	if err := enc.Decode(strings.NewReader(`{"hobbies.0": "tennis", "hobbies.1": "coding"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(buf.String()); s != `{"hobbies":["tennis","coding"]}` {
+		t.Errorf("unexpected output %s", s)
+	}
+}
+
+func TestUnflattenEscaping(t *testing.T) {
+	input := `{"a\\.b": 1, "a.b": 2, "c\\\\d": 3, "e\\[0\\]": 4, "f\\[0\\][1]": 5}`
+	expected := `{"a":{"b":2},"a.b":1,"c\\d":3,"e[0]":4,"f[0]":[null,5]}`
+
+	b, err := Unflatten(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := strings.TrimSpace(string(b)); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
+
+var roundTripInputs = []string{
+	`{"name": "Bob Smith", "address": {"city": "Boresville", "zipcode": 13943}, "hobbies": ["tennis", "coding"]}`,
+	`[1, [2, {"b": 3}], null]`,
+	`{"a": [[], {}, [[]]], "b": {}, "c": null}`,
+	`{"a": {"b": [null, null]}}`,
+	`{"a.b": {"c[0]": [1]}, "a": {"b": 2}}`,
+	`{"a\\b": 1, "[": 2, "]": 3, "a": "[0]"}`,
+	`{"": 1, "a": {"": {"": 2}}, "b": [{"": 3}]}`,
+	`{"0": 1, "a": {"1": [{"2": 3}]}}`,
+	`{"n": 1.50, "big": 12345678901234567890}`,
+}
+
+func TestRoundTrip(t *testing.T) {
+	notations := []ArrayNotation{
+		BracketNotation,
+		DottedBracketNotation,
+		PlainNotation,
+	}
+
+	for _, input := range roundTripInputs {
+		for _, n := range notations {
+			flat := bytes.NewBuffer(nil)
+			enc := NewEncoder(flat, WithEmptyCollections(true), WithArrayNotation(n))
+
+			if err := enc.ConvertMap(strings.NewReader(input)); err != nil {
+				t.Errorf("%s: %s", input, err)
+				continue
+			}
+
+			out := bytes.NewBuffer(nil)
+			dec := NewEncoder(out, WithArrayNotation(n))
+
+			if err := dec.Decode(bytes.NewReader(flat.Bytes())); err != nil {
+				t.Errorf("%s (%s): %s", input, n, err)
+				continue
+			}
+
+			var expected, actual interface{}
+
+			if err := json.Unmarshal([]byte(input), &expected); err != nil {
+				panic(err)
+			}
+
+			if err := json.Unmarshal(out.Bytes(), &actual); err != nil {
+				t.Errorf("%s (%s): %s", input, n, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("%s (%s): got %s via %s", input, n, strings.TrimSpace(out.String()), strings.TrimSpace(flat.String()))
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package flatjson
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Validate flattens a JSON input without writing any output and returns
+// the first error, such as invalid JSON or exceeding a limit set by
+// WithMaxPairs or WithMaxInputBytes. Options that change the output are
+// checked as well: WithStrictKeys reports duplicate keys, WithValidateKeys
+// reports keys with control characters, and nesting deeper than WithMaxDepth
+// is reported as an error wrapping ErrLimitExceeded rather than being kept
+// whole.
+func Validate(r io.Reader, opts ...Option) error {
+	o := newOptions(opts)
+
+	// Flatten all levels so the full depth is seen.
+	maxDepth := o.maxDepth
+	o.maxDepth = 0
+
+	p := newReaderParser(context.Background(), r, &o)
+
+	var seen map[string]struct{}
+
+	if o.strictKeys {
+		seen = make(map[string]struct{})
+	}
+
+	for {
+		pair, err := p.next()
+
+		if err != io.EOF && err != nil {
+			return err
+		}
+
+		// Filtered values are checked as well, once their value ends.
+		if maxDepth > 0 && p.depth > maxDepth {
+			return fmt.Errorf("%w: nesting deeper than %d", ErrLimitExceeded, maxDepth)
+		}
+
+		// Continue with the next top-level value, if any.
+		if err == io.EOF && p.started {
+			p.reset()
+			continue
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if seen != nil {
+			if _, ok := seen[pair.Key]; ok {
+				return fmt.Errorf("flatjson: duplicate key %q", pair.Key)
+			}
+
+			seen[pair.Key] = struct{}{}
+		}
+	}
+}
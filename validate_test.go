@@ -0,0 +1,44 @@
+package flatjson
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		Input   string
+		Options []Option
+		Error   string
+	}{
+		{`{"a": {"b": [1, 2]}} {"c": 3}`, nil, ""},
+		{`{"a": {"b": }}`, nil, `flatjson: invalid token at offset 10 (path "a.b"): missing value after object key`},
+		{`{"a": [1, 2, 3]}`, []Option{WithMaxPairs(2)}, "flatjson: limit exceeded: more than 2 pairs"},
+		{`{"a": {"b": 1}}`, []Option{WithMaxDepth(2)}, ""},
+		{`{"a": {"b": {"c": 1}}}`, []Option{WithMaxDepth(2)}, "flatjson: limit exceeded: nesting deeper than 2"},
+		{`{"a": {"b": {}}}`, []Option{WithMaxDepth(2), WithKeyFilter("x")}, "flatjson: limit exceeded: nesting deeper than 2"},
+		{`{"a.b": 1, "a": {"b": 2}}`, []Option{WithKeyEscaping(false)}, ""},
+		{`{"a.b": 1, "a": {"b": 2}}`, []Option{WithKeyEscaping(false), WithStrictKeys(true)}, `flatjson: duplicate key "a.b"`},
+		{`{"a": 1} {"a": 2}`, []Option{WithStrictKeys(true)}, `flatjson: duplicate key "a"`},
+		{`{"a\u0001": 1}`, []Option{WithValidateKeys(true)}, `flatjson: key "a\x01" contains control character U+0001`},
+	}
+
+	for _, test := range tests {
+		err := Validate(strings.NewReader(test.Input), test.Options...)
+
+		if test.Error == "" && err != nil {
+			t.Errorf("%s: unexpected error %s", test.Input, err)
+		}
+
+		if test.Error != "" && (err == nil || err.Error() != test.Error) {
+			t.Errorf("%s: expected error %s, got %v", test.Input, test.Error, err)
+		}
+	}
+
+	err := Validate(strings.NewReader(`[[[1]]]`), WithMaxDepth(1))
+
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package flatjson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokenReader is the subset of json.Decoder used by the parser, so values
+// already in memory can be flattened without encoding them first.
+type tokenReader interface {
+	Token() (json.Token, error)
+	More() bool
+	InputOffset() int64
+}
+
+// tokenSlice replays the tokens of a value. Its offset is the index of the
+// next token rather than a byte offset.
+type tokenSlice struct {
+	toks []json.Token
+	i    int
+}
+
+func (s *tokenSlice) Token() (json.Token, error) {
+	if s.i >= len(s.toks) {
+		return nil, io.EOF
+	}
+
+	tok := s.toks[s.i]
+	s.i++
+
+	return tok, nil
+}
+
+func (s *tokenSlice) More() bool {
+	return s.i < len(s.toks) && s.toks[s.i] != rbrace && s.toks[s.i] != rsquare
+}
+
+func (s *tokenSlice) InputOffset() int64 {
+	return int64(s.i)
+}
+
+// Depth past which values are handed to encoding/json, which detects
+// pointer cycles rather than recursing forever.
+const maxValueDepth = 1000
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// appendTokens appends the tokens a json.Decoder would produce when reading
+// the encoding of v. Common types are handled directly and the rest are
+// walked with reflection. Types that customize their encoding, such as
+// marshalers, are encoded and decoded again.
+func appendTokens(toks []json.Token, v interface{}, depth int) ([]json.Token, error) {
+	switch x := v.(type) {
+	case nil:
+		return append(toks, nil), nil
+	case string:
+		return append(toks, validString(x)), nil
+	case bool:
+		return append(toks, x), nil
+	case json.Number:
+		return appendNumber(toks, x)
+	case int:
+		return append(toks, json.Number(strconv.Itoa(x))), nil
+	case int64:
+		return append(toks, json.Number(strconv.FormatInt(x, 10))), nil
+	case float64:
+		return appendFloat(toks, x, 64)
+	case map[string]interface{}:
+		if x == nil {
+			return append(toks, nil), nil
+		}
+
+		if depth > maxValueDepth {
+			return appendEncoded(toks, v)
+		}
+
+		keys := make([]string, 0, len(x))
+
+		for k := range x {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		toks = append(toks, lbrace)
+
+		for _, k := range keys {
+			var err error
+
+			toks = append(toks, validString(k))
+
+			if toks, err = appendTokens(toks, x[k], depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(toks, rbrace), nil
+	case []interface{}:
+		if x == nil {
+			return append(toks, nil), nil
+		}
+
+		if depth > maxValueDepth {
+			return appendEncoded(toks, v)
+		}
+
+		toks = append(toks, lsquare)
+
+		for _, e := range x {
+			var err error
+
+			if toks, err = appendTokens(toks, e, depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(toks, rsquare), nil
+	}
+
+	return appendValue(toks, reflect.ValueOf(v), depth)
+}
+
+// appendValue appends the tokens of a value using reflection.
+func appendValue(toks []json.Token, v reflect.Value, depth int) ([]json.Token, error) {
+	if !v.IsValid() {
+		return append(toks, nil), nil
+	}
+
+	t := v.Type()
+
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return appendEncoded(toks, v.Interface())
+	}
+
+	// Methods with pointer receivers are used if the value is addressable,
+	// as they are by encoding/json.
+	if t.Kind() != reflect.Pointer && v.CanAddr() {
+		pt := reflect.PointerTo(t)
+
+		if pt.Implements(marshalerType) || pt.Implements(textMarshalerType) {
+			return appendEncoded(toks, v.Addr().Interface())
+		}
+	}
+
+	if depth > maxValueDepth {
+		return appendEncoded(toks, v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return append(toks, v.Bool()), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return append(toks, json.Number(strconv.FormatInt(v.Int(), 10))), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return append(toks, json.Number(strconv.FormatUint(v.Uint(), 10))), nil
+
+	case reflect.Float32:
+		return appendFloat(toks, v.Float(), 32)
+
+	case reflect.Float64:
+		return appendFloat(toks, v.Float(), 64)
+
+	case reflect.String:
+		// A json.Number may be held by a named string type.
+		if t == reflect.TypeOf(json.Number("")) {
+			return appendNumber(toks, json.Number(v.String()))
+		}
+
+		return append(toks, validString(v.String())), nil
+
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return append(toks, nil), nil
+		}
+
+		return appendValue(toks, v.Elem(), depth+1)
+
+	case reflect.Struct:
+		return appendStruct(toks, v, depth)
+
+	case reflect.Map:
+		// Keys other than plain strings are converted by encoding/json.
+		if t.Key().Kind() != reflect.String || t.Key().Implements(textMarshalerType) {
+			break
+		}
+
+		if v.IsNil() {
+			return append(toks, nil), nil
+		}
+
+		keys := v.MapKeys()
+
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		toks = append(toks, lbrace)
+
+		for _, k := range keys {
+			var err error
+
+			toks = append(toks, validString(k.String()))
+
+			if toks, err = appendValue(toks, v.MapIndex(k), depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(toks, rbrace), nil
+
+	case reflect.Slice:
+		// Byte slices are encoded as base64 strings.
+		if t.Elem().Kind() == reflect.Uint8 {
+			break
+		}
+
+		if v.IsNil() {
+			return append(toks, nil), nil
+		}
+
+		fallthrough
+
+	case reflect.Array:
+		toks = append(toks, lsquare)
+
+		for i := 0; i < v.Len(); i++ {
+			var err error
+
+			if toks, err = appendValue(toks, v.Index(i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+		return append(toks, rsquare), nil
+	}
+
+	return appendEncoded(toks, v.Interface())
+}
+
+// appendEncoded appends the tokens of v by encoding it as JSON and
+// decoding the result.
+func appendEncoded(toks []json.Token, v interface{}) ([]json.Token, error) {
+	buf := bufPool.Get().(*bytes.Buffer)
+
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			bufPool.Put(buf)
+		}
+	}()
+
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	// The encoding has no byte order mark to skip, so the decoder does
+	// not need to be buffered like newJSONDecoder.
+	dec := json.NewDecoder(buf)
+	dec.UseNumber()
+
+	for {
+		tok, err := dec.Token()
+
+		if err == io.EOF {
+			return toks, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		toks = append(toks, tok)
+	}
+}
+
+// appendNumber appends a json.Number, which is validated the same way
+// encoding/json does.
+func appendNumber(toks []json.Token, n json.Number) ([]json.Token, error) {
+	if n == "" {
+		n = "0"
+	}
+
+	if !strings.ContainsAny(n.String()[:1], "-0123456789") || !json.Valid([]byte(n)) {
+		return appendEncoded(toks, n)
+	}
+
+	return append(toks, n), nil
+}
+
+// appendFloat appends a float formatted the way encoding/json formats it.
+func appendFloat(toks []json.Token, f float64, bits int) ([]json.Token, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, &json.UnsupportedValueError{
+			Value: reflect.ValueOf(f),
+			Str:   strconv.FormatFloat(f, 'g', -1, bits),
+		}
+	}
+
+	// Use exponents for very small and very large values.
+	format := byte('f')
+
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+
+	// Clean up e-09 to e-9.
+	if n := len(b); format == 'e' && n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+		b[n-2] = b[n-1]
+		b = b[:n-1]
+	}
+
+	return append(toks, json.Number(b)), nil
+}
+
+// Largest exponent plainNumber expands, which bounds the length of the
+// result.
+const maxPlainExponent = 1000
+
+// plainNumber returns the number without an exponent by moving its decimal
+// point, e.g. 1.5e3 as 1500, so no precision is lost. Numbers with larger
+// exponents than maxPlainExponent are returned as is.
+func plainNumber(n json.Number) json.Number {
+	s := string(n)
+	i := strings.IndexAny(s, "eE")
+
+	if i < 0 {
+		return n
+	}
+
+	exp, err := strconv.Atoi(s[i+1:])
+
+	if err != nil || exp > maxPlainExponent || exp < -maxPlainExponent {
+		return n
+	}
+
+	mant, neg := strings.CutPrefix(s[:i], "-")
+	whole, frac, _ := strings.Cut(mant, ".")
+	digits := whole + frac
+
+	// Position of the decimal point in the digits.
+	point := len(whole) + exp
+
+	var b strings.Builder
+
+	switch {
+	case point <= 0:
+		b.WriteString("0.")
+		b.WriteString(strings.Repeat("0", -point))
+		b.WriteString(digits)
+	case point >= len(digits):
+		b.WriteString(digits)
+		b.WriteString(strings.Repeat("0", point-len(digits)))
+	default:
+		b.WriteString(digits[:point])
+		b.WriteByte('.')
+		b.WriteString(digits[point:])
+	}
+
+	r := b.String()
+
+	// Drop insignificant zeros.
+	if strings.Contains(r, ".") {
+		r = strings.TrimRight(strings.TrimRight(r, "0"), ".")
+	}
+
+	for len(r) > 1 && r[0] == '0' && r[1] != '.' {
+		r = r[1:]
+	}
+
+	if neg {
+		r = "-" + r
+	}
+
+	return json.Number(r)
+}
+
+// validString replaces invalid UTF-8 with the replacement character, byte
+// by byte, as encoding/json does.
+func validString(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+
+	for _, r := range s {
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
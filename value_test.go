@@ -0,0 +1,141 @@
+package flatjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type valueUser struct {
+	Name    string            `json:"name"`
+	Tags    []string          `json:"tags,omitempty"`
+	Address *valueAddress     `json:"address"`
+	Extra   map[string]string `json:"-"`
+}
+
+type valueAddress struct {
+	City string
+	Zip  int
+}
+
+type valueText int
+
+func (t valueText) MarshalText() ([]byte, error) {
+	return []byte("text"), nil
+}
+
+type valuePtrMarshaler struct{}
+
+func (*valuePtrMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"ptr"`), nil
+}
+
+func TestParseValueOf(t *testing.T) {
+	zip := 13943
+
+	values := []interface{}{
+		nil,
+		"hello",
+		"invalid \xff utf-8",
+		true,
+		42,
+		int8(-8),
+		uint64(math.MaxUint64),
+		3.5,
+		1e21,
+		1e-7,
+		float32(0.1),
+		json.Number("1.50"),
+		json.Number(""),
+		&zip,
+		map[string]interface{}{"b": 1, "a": []interface{}{1, "x", nil}},
+		map[string]int{"z": 1, "y": 2},
+		map[int]string{2: "b", 10: "a"},
+		map[string]interface{}(nil),
+		[]interface{}(nil),
+		[]string{"tennis", "coding"},
+		[]string(nil),
+		[2]int{1, 2},
+		[]byte("bytes"),
+		[][]int{{1}, {}},
+		valueUser{Name: "Bob", Address: &valueAddress{City: "Boresville", Zip: 13943}},
+		&valueUser{Tags: []string{"a"}},
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		json.RawMessage(`{"raw": [1, 2]}`),
+		map[valueText]int{1: 1},
+		[]valueText{1, 2},
+		[]valuePtrMarshaler{{}},
+		[]interface{}{valuePtrMarshaler{}},
+	}
+
+	o := newOptions(nil)
+
+	for _, v := range values {
+		b, err := json.Marshal(v)
+
+		if err != nil {
+			panic(err)
+		}
+
+		expected, err := parseJSON(context.Background(), bytes.NewReader(b), &o)
+
+		if err != nil {
+			panic(err)
+		}
+
+		actual, err := parseValueOf(v, &o)
+
+		if err != nil {
+			t.Errorf("%s: %s", b, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%s: expected %v, got %v", b, expected, actual)
+		}
+	}
+}
+
+func TestParseValueOfUnsupported(t *testing.T) {
+	o := newOptions(nil)
+
+	values := []interface{}{
+		math.NaN(),
+		map[string]interface{}{"a": math.Inf(1)},
+		make(chan int),
+	}
+
+	for _, v := range values {
+		if _, err := parseValueOf(v, &o); err == nil {
+			t.Errorf("%#v: expected error", v)
+		}
+	}
+}
+
+func TestPlainNumber(t *testing.T) {
+	tests := map[string]string{
+		"1e20":      "100000000000000000000",
+		"1E+2":      "100",
+		"1.5e3":     "1500",
+		"-1.5e-3":   "-0.0015",
+		"1.23456e2": "123.456",
+		"0.5e1":     "5",
+		"1.50e1":    "15",
+		"12e-1":     "1.2",
+		"0e0":       "0",
+		"123":       "123",
+		"1.5":       "1.5",
+		"1e-7":      "0.0000001",
+		"1e1001":    "1e1001",
+	}
+
+	for in, exp := range tests {
+		if n := plainNumber(json.Number(in)); string(n) != exp {
+			t.Errorf("%s: expected %s, got %s", in, exp, n)
+		}
+	}
+}
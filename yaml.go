@@ -0,0 +1,73 @@
+//go:build yaml
+// +build yaml
+
+package flatjson
+
+import (
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConvertMapYAML re-encodes a YAML document into a flat JSON map. It is only
+// available when built with the yaml build tag.
+func (f *Encoder) ConvertMapYAML(r io.Reader) error {
+	v, err := decodeYAML(r)
+
+	if err != nil {
+		return err
+	}
+
+	return f.EncodeMap(v)
+}
+
+// ConvertArrayYAML re-encodes a YAML document into a flat JSON array. It is
+// only available when built with the yaml build tag.
+func (f *Encoder) ConvertArrayYAML(r io.Reader) error {
+	v, err := decodeYAML(r)
+
+	if err != nil {
+		return err
+	}
+
+	return f.EncodeArray(v)
+}
+
+// decodeYAML decodes a YAML document into a value that can be encoded as JSON.
+func decodeYAML(r io.Reader) (interface{}, error) {
+	var v interface{}
+
+	if err := yaml.NewDecoder(r).Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return normalizeYAML(v), nil
+}
+
+// normalizeYAML converts maps with non-string keys, which cannot be encoded
+// as JSON, into maps with string keys.
+func normalizeYAML(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = normalizeYAML(e)
+		}
+
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+
+		for k, e := range x {
+			m[fmt.Sprint(k)] = normalizeYAML(e)
+		}
+
+		return m
+
+	case []interface{}:
+		for i, e := range x {
+			x[i] = normalizeYAML(e)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,33 @@
+//go:build yaml
+// +build yaml
+
+package flatjson
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConvertMapYAML(t *testing.T) {
+	input := `
+name: Bob Smith
+address:
+  city: Boresville
+  zipcode: 13943
+1: one
+`
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf)
+
+	if err := enc.ConvertMapYAML(strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"1":"one","address.city":"Boresville","address.zipcode":13943,"name":"Bob Smith"}`
+
+	if s := strings.TrimSpace(buf.String()); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}